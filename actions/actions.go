@@ -0,0 +1,346 @@
+package actions
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"textsurf/apperr"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/input"
+	"github.com/go-rod/rod/lib/proto"
+)
+
+// 单个步骤的默认超时时间
+const defaultStepTimeout = 30 * time.Second
+
+// 支持的操作类型
+const (
+	Click       = "click"
+	Type        = "type"
+	Select      = "select"
+	Press       = "press"
+	Hover       = "hover"
+	Scroll      = "scroll"
+	WaitFor     = "wait_for"
+	WaitForText = "wait_for_text"
+	Wait        = "wait"
+	Navigate    = "navigate"
+	Evaluate    = "evaluate"
+)
+
+// 按键名称映射，单个字符直接按字符输入
+var namedKeys = map[string]input.Key{
+	"enter":      input.Enter,
+	"tab":        input.Tab,
+	"escape":     input.Escape,
+	"esc":        input.Escape,
+	"backspace":  input.Backspace,
+	"delete":     input.Delete,
+	"space":      input.Space,
+	"arrowup":    input.ArrowUp,
+	"arrowdown":  input.ArrowDown,
+	"arrowleft":  input.ArrowLeft,
+	"arrowright": input.ArrowRight,
+	"home":       input.Home,
+	"end":        input.End,
+	"pageup":     input.PageUp,
+	"pagedown":   input.PageDown,
+}
+
+// 判断脚本是否已经是函数定义
+var functionRegexp = regexp.MustCompile(`^\s*(async\s+)?(function\b|\([^)]*\)\s*=>|[A-Za-z_$][\w$]*\s*=>)`)
+
+// Action 提取内容前在页面上执行的操作步骤
+type Action struct {
+	// Action 操作类型
+	Action string `json:"action"`
+	// Selector 目标元素的 CSS 选择器
+	Selector string `json:"selector,omitempty"`
+	// Text type 输入的文本，wait_for_text 等待的文本，select 按文本选择的选项
+	Text string `json:"text,omitempty"`
+	// Value select 按 value 选择的选项
+	Value string `json:"value,omitempty"`
+	// Key press 的按键，例如 Enter、Tab、ArrowDown
+	Key string `json:"key,omitempty"`
+	// URL navigate 的目标地址
+	URL string `json:"url,omitempty"`
+	// Script evaluate 执行的 JS 表达式或函数
+	Script string `json:"script,omitempty"`
+	// Ms wait 的等待毫秒数
+	Ms int `json:"ms,omitempty"`
+	// X、Y scroll 的滚动距离，未指定选择器和距离时滚动到底部
+	X float64 `json:"x,omitempty"`
+	Y float64 `json:"y,omitempty"`
+	// Clear type 前是否清空输入框
+	Clear bool `json:"clear,omitempty"`
+	// TimeoutMs 步骤超时毫秒数，默认 30 秒
+	TimeoutMs int `json:"timeout_ms,omitempty"`
+	// Optional 为 true 时步骤失败不中断后续操作
+	Optional bool `json:"optional,omitempty"`
+}
+
+// NavigateHook 在 navigate 步骤打开目标地址前调用，例如等待站点访问限制，返回错误时步骤失败
+type NavigateHook func(ctx context.Context, targetURL string) error
+
+// Parse 解析 JSON 格式的操作列表
+func Parse(raw string) ([]Action, error) {
+	var steps []Action
+	if err := json.Unmarshal([]byte(raw), &steps); err != nil {
+		return nil, fmt.Errorf("操作列表必须是 JSON 数组: %v", err)
+	}
+	return steps, nil
+}
+
+// Validate 校验每个步骤的必需字段
+func Validate(steps []Action) error {
+	for i, step := range steps {
+		if err := step.validate(); err != nil {
+			return fmt.Errorf("第 %d 个操作 (%s) 无效: %v", i+1, step.Action, err)
+		}
+	}
+	return nil
+}
+
+// validate 校验单个步骤
+func (a Action) validate() error {
+	switch a.Action {
+	case Click, Type, Hover, WaitFor:
+		if a.Selector == "" {
+			return fmt.Errorf("缺少 selector")
+		}
+	case Select:
+		if a.Selector == "" {
+			return fmt.Errorf("缺少 selector")
+		}
+		if a.Value == "" && a.Text == "" {
+			return fmt.Errorf("需要 value 或 text")
+		}
+	case Press:
+		if _, err := parseKey(a.Key); err != nil {
+			return err
+		}
+	case WaitForText:
+		if a.Text == "" {
+			return fmt.Errorf("缺少 text")
+		}
+	case Wait:
+		if a.Ms <= 0 {
+			return fmt.Errorf("ms 必须大于 0")
+		}
+	case Navigate:
+		if a.URL == "" {
+			return fmt.Errorf("缺少 url")
+		}
+	case Evaluate:
+		if strings.TrimSpace(a.Script) == "" {
+			return fmt.Errorf("缺少 script")
+		}
+	case Scroll:
+	default:
+		return fmt.Errorf("不支持的操作类型")
+	}
+
+	if a.TimeoutMs < 0 {
+		return fmt.Errorf("timeout_ms 不能为负数")
+	}
+	return nil
+}
+
+// Run 按顺序在页面上执行操作，返回 evaluate 步骤的结果
+// beforeNavigate 不为空时在每个 navigate 步骤导航前调用
+func Run(page *rod.Page, steps []Action, beforeNavigate NavigateHook) ([]interface{}, error) {
+	var results []interface{}
+
+	for i, step := range steps {
+		log.Printf("执行第 %d 个操作: %s %s", i+1, step.Action, step.Selector)
+
+		result, err := step.run(page, beforeNavigate)
+		if err != nil {
+			if step.Optional {
+				log.Printf("可选操作失败，继续执行: %v", err)
+				continue
+			}
+			return results, fmt.Errorf("第 %d 个操作 (%s) 失败: %w", i+1, step.Action, err)
+		}
+		if step.Action == Evaluate {
+			results = append(results, result)
+		}
+	}
+
+	return results, nil
+}
+
+// run 在超时限制内执行单个步骤
+// 步骤超时而请求本身未超时时，等待元素的步骤返回 selector_not_found
+func (a Action) run(page *rod.Page, beforeNavigate NavigateHook) (interface{}, error) {
+	timeout := defaultStepTimeout
+	if a.TimeoutMs > 0 {
+		timeout = time.Duration(a.TimeoutMs) * time.Millisecond
+	}
+	p := page.Timeout(timeout)
+	defer p.CancelTimeout()
+
+	result, err := a.do(p, page, beforeNavigate)
+	if err != nil && a.waitsForElement() &&
+		errors.Is(p.GetContext().Err(), context.DeadlineExceeded) && page.GetContext().Err() == nil {
+		message := fmt.Sprintf("等待元素 '%s' 超时 (%v)", a.Selector, timeout)
+		if a.Action == WaitForText {
+			message = fmt.Sprintf("等待文本 '%s' 超时 (%v)", a.Text, timeout)
+		}
+		return nil, &apperr.Error{Code: apperr.SelectorNotFound, Message: message, Err: err}
+	}
+	return result, err
+}
+
+// waitsForElement 判断步骤是否需要等待元素出现
+func (a Action) waitsForElement() bool {
+	switch a.Action {
+	case Click, Type, Select, Hover, WaitFor, WaitForText:
+		return true
+	case Press, Scroll:
+		return a.Selector != ""
+	}
+	return false
+}
+
+// do 执行单个步骤，p 为带步骤超时的页面
+func (a Action) do(p, page *rod.Page, beforeNavigate NavigateHook) (interface{}, error) {
+	switch a.Action {
+	case Click:
+		el, err := p.Element(a.Selector)
+		if err != nil {
+			return nil, err
+		}
+		return nil, el.Click(proto.InputMouseButtonLeft, 1)
+
+	case Type:
+		el, err := p.Element(a.Selector)
+		if err != nil {
+			return nil, err
+		}
+		if a.Clear {
+			if err := el.SelectAllText(); err != nil {
+				return nil, err
+			}
+		}
+		return nil, el.Input(a.Text)
+
+	case Select:
+		el, err := p.Element(a.Selector)
+		if err != nil {
+			return nil, err
+		}
+		if a.Value != "" {
+			return nil, el.Select([]string{fmt.Sprintf(`[value=%q]`, a.Value)}, true, rod.SelectorTypeCSSSector)
+		}
+		return nil, el.Select([]string{"^" + regexp.QuoteMeta(a.Text) + "$"}, true, rod.SelectorTypeRegex)
+
+	case Press:
+		key, _ := parseKey(a.Key)
+		if a.Selector != "" {
+			el, err := p.Element(a.Selector)
+			if err != nil {
+				return nil, err
+			}
+			if err := el.Focus(); err != nil {
+				return nil, err
+			}
+		}
+		return nil, p.Keyboard.Type(key)
+
+	case Hover:
+		el, err := p.Element(a.Selector)
+		if err != nil {
+			return nil, err
+		}
+		return nil, el.Hover()
+
+	case Scroll:
+		if a.Selector != "" {
+			el, err := p.Element(a.Selector)
+			if err != nil {
+				return nil, err
+			}
+			return nil, el.ScrollIntoView()
+		}
+		if a.X == 0 && a.Y == 0 {
+			_, err := p.Eval(`() => window.scrollTo(0, document.body.scrollHeight)`)
+			return nil, err
+		}
+		_, err := p.Eval(`(x, y) => window.scrollBy(x, y)`, a.X, a.Y)
+		return nil, err
+
+	case WaitFor:
+		el, err := p.Element(a.Selector)
+		if err != nil {
+			return nil, err
+		}
+		return nil, el.WaitVisible()
+
+	case WaitForText:
+		selector := a.Selector
+		if selector == "" {
+			selector = "body"
+		}
+		_, err := p.ElementR(selector, regexp.QuoteMeta(a.Text))
+		return nil, err
+
+	case Wait:
+		// 固定等待不受步骤超时限制，只受页面上下文控制
+		select {
+		case <-time.After(time.Duration(a.Ms) * time.Millisecond):
+			return nil, nil
+		case <-page.GetContext().Done():
+			return nil, page.GetContext().Err()
+		}
+
+	case Navigate:
+		if beforeNavigate != nil {
+			if err := beforeNavigate(p.GetContext(), a.URL); err != nil {
+				return nil, err
+			}
+		}
+		if err := p.Navigate(a.URL); err != nil {
+			return nil, err
+		}
+		return nil, p.WaitLoad()
+
+	case Evaluate:
+		res, err := p.Eval(JSFunction(a.Script))
+		if err != nil {
+			return nil, err
+		}
+		return res.Value.Val(), nil
+	}
+
+	return nil, fmt.Errorf("不支持的操作类型")
+}
+
+// JSFunction 将 JS 表达式包装为函数，已经是函数定义时原样返回
+func JSFunction(script string) string {
+	if functionRegexp.MatchString(script) {
+		return script
+	}
+	return "() => (" + strings.TrimRight(strings.TrimSpace(script), ";") + ")"
+}
+
+// parseKey 将按键名称转换为 rod 按键
+func parseKey(name string) (input.Key, error) {
+	if name == "" {
+		return 0, fmt.Errorf("缺少 key")
+	}
+	if key, ok := namedKeys[strings.ToLower(name)]; ok {
+		return key, nil
+	}
+	if runes := []rune(name); len(runes) == 1 && runes[0] >= ' ' && runes[0] <= '~' {
+		return input.Key(runes[0]), nil
+	}
+	return 0, fmt.Errorf("不支持的按键 '%s'", name)
+}
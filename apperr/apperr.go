@@ -0,0 +1,167 @@
+package apperr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+
+	"github.com/go-rod/rod"
+)
+
+// Code 稳定的错误码，客户端根据错误码判断错误类型和是否重试
+type Code string
+
+// 错误码
+const (
+	// InvalidRequest 请求参数无效
+	InvalidRequest Code = "invalid_request"
+	// NotFound 会话、凭证等资源不存在
+	NotFound Code = "not_found"
+	// NavigationFailed 页面导航失败，例如域名无法解析、连接被拒绝
+	NavigationFailed Code = "navigation_failed"
+	// SelectorNotFound 在超时前没有找到选择器对应的元素
+	SelectorNotFound Code = "selector_not_found"
+	// Timeout 请求超时
+	Timeout Code = "timeout"
+	// ClientClosed 客户端在响应前断开连接
+	ClientClosed Code = "client_closed"
+	// BrowserCrashed 浏览器崩溃或连接已断开
+	BrowserCrashed Code = "browser_crashed"
+	// CaptchaDetected 页面出现验证码或人机验证
+	CaptchaDetected Code = "captcha_detected"
+	// LoginRejected 站点拒绝登录，例如验证码错误
+	LoginRejected Code = "login_rejected"
+	// QueueFull 并发请求过多，等待队列已满
+	QueueFull Code = "queue_full"
+	// Unsupported 模块不支持请求的登录方式
+	Unsupported Code = "unsupported"
+	// Internal 其他内部错误
+	Internal Code = "internal"
+)
+
+// 错误码对应的 HTTP 状态码
+var statuses = map[Code]int{
+	InvalidRequest:   http.StatusBadRequest,
+	NotFound:         http.StatusNotFound,
+	NavigationFailed: http.StatusBadGateway,
+	SelectorNotFound: http.StatusUnprocessableEntity,
+	Timeout:          http.StatusGatewayTimeout,
+	ClientClosed:     499, // 沿用 nginx 的 Client Closed Request
+	BrowserCrashed:   http.StatusServiceUnavailable,
+	CaptchaDetected:  http.StatusForbidden,
+	LoginRejected:    http.StatusUnauthorized,
+	QueueFull:        http.StatusTooManyRequests,
+	Unsupported:      http.StatusNotImplemented,
+	Internal:         http.StatusInternalServerError,
+}
+
+// 可以直接重试的错误码
+var retryable = map[Code]bool{
+	NavigationFailed: true,
+	Timeout:          true,
+	BrowserCrashed:   true,
+	QueueFull:        true,
+}
+
+// Error 带错误码的错误
+type Error struct {
+	Code    Code
+	Message string
+	Err     error
+}
+
+// Error 返回错误信息，包含被包装的错误
+func (e *Error) Error() string {
+	switch {
+	case e.Err == nil:
+		return e.Message
+	case e.Message == "":
+		return e.Err.Error()
+	}
+	return e.Message + ": " + e.Err.Error()
+}
+
+// Unwrap 返回被包装的错误
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// New 创建带错误码的错误
+func New(code Code, format string, args ...interface{}) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// Wrap 包装错误并附加说明
+// err 已带具体错误码、超时、客户端断开或浏览器崩溃时保留原错误码，否则使用 code
+func Wrap(err error, code Code, format string, args ...interface{}) *Error {
+	if detected := classify(err); detected != "" && detected != Internal {
+		code = detected
+	}
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...), Err: err}
+}
+
+// From 为没有错误码的错误指定错误码，错误信息不变
+func From(err error, code Code) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	if detected := classify(err); detected != "" {
+		code = detected
+	}
+	return &Error{Code: code, Err: err}
+}
+
+// CodeOf 返回错误的错误码，无法识别时返回 Internal
+func CodeOf(err error) Code {
+	if code := classify(err); code != "" {
+		return code
+	}
+	return Internal
+}
+
+// Status 返回错误对应的 HTTP 状态码
+func Status(err error) int {
+	return statuses[CodeOf(err)]
+}
+
+// Retryable 判断错误是否可以直接重试
+func Retryable(err error) bool {
+	return retryable[CodeOf(err)]
+}
+
+// classify 根据错误链识别错误码，无法识别时返回空字符串
+func classify(err error) Code {
+	var e *Error
+	var navigation *rod.NavigationError
+	var elementNotFound *rod.ElementNotFoundError
+	var pageNotFound *rod.PageNotFoundError
+
+	switch {
+	case err == nil:
+		return ""
+	case errors.As(err, &e):
+		return e.Code
+	case errors.Is(err, context.DeadlineExceeded):
+		return Timeout
+	case errors.Is(err, context.Canceled):
+		return ClientClosed
+	case errors.As(err, &navigation):
+		return NavigationFailed
+	case errors.As(err, &elementNotFound):
+		return SelectorNotFound
+	case errors.As(err, &pageNotFound),
+		errors.Is(err, io.EOF),
+		errors.Is(err, io.ErrUnexpectedEOF),
+		errors.Is(err, net.ErrClosed),
+		errors.Is(err, syscall.ECONNRESET),
+		errors.Is(err, syscall.ECONNREFUSED),
+		errors.Is(err, syscall.EPIPE):
+		return BrowserCrashed
+	}
+	return ""
+}
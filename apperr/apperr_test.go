@@ -0,0 +1,104 @@
+package apperr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		code      Code
+		status    int
+		retryable bool
+	}{
+		{"app error", New(NotFound, "missing"), NotFound, http.StatusNotFound, false},
+		{"wrapped app error", fmt.Errorf("outer: %w", New(CaptchaDetected, "captcha")), CaptchaDetected, http.StatusForbidden, false},
+		{"deadline", context.DeadlineExceeded, Timeout, http.StatusGatewayTimeout, true},
+		{"canceled", fmt.Errorf("wait: %w", context.Canceled), ClientClosed, 499, false},
+		{"connection closed", io.EOF, BrowserCrashed, http.StatusServiceUnavailable, true},
+		{"queue full", New(QueueFull, "busy"), QueueFull, http.StatusTooManyRequests, true},
+		{"login rejected", New(LoginRejected, "wrong code"), LoginRejected, http.StatusUnauthorized, false},
+		{"unsupported", New(Unsupported, "no sms"), Unsupported, http.StatusNotImplemented, false},
+		{"unknown", errors.New("boom"), Internal, http.StatusInternalServerError, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CodeOf(tt.err); got != tt.code {
+				t.Errorf("CodeOf() = %s, want %s", got, tt.code)
+			}
+			if got := Status(tt.err); got != tt.status {
+				t.Errorf("Status() = %d, want %d", got, tt.status)
+			}
+			if got := Retryable(tt.err); got != tt.retryable {
+				t.Errorf("Retryable() = %v, want %v", got, tt.retryable)
+			}
+		})
+	}
+}
+
+func TestWrap(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		code Code
+		want Code
+	}{
+		{"plain error uses code", errors.New("boom"), NavigationFailed, NavigationFailed},
+		{"keeps specific code", New(SelectorNotFound, "missing"), Internal, SelectorNotFound},
+		{"keeps timeout", context.DeadlineExceeded, Internal, Timeout},
+		{"internal is replaced", New(Internal, "boom"), QueueFull, QueueFull},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wrapped := Wrap(tt.err, tt.code, "context %d", 1)
+			if wrapped.Code != tt.want {
+				t.Errorf("Wrap().Code = %s, want %s", wrapped.Code, tt.want)
+			}
+			if !errors.Is(wrapped, tt.err) {
+				t.Error("Wrap() does not unwrap to the original error")
+			}
+			if want := "context 1: " + tt.err.Error(); wrapped.Error() != want {
+				t.Errorf("Error() = %q, want %q", wrapped.Error(), want)
+			}
+		})
+	}
+}
+
+func TestFrom(t *testing.T) {
+	original := New(NotFound, "missing")
+	if got := From(original, Internal); got != original {
+		t.Errorf("From(app error) = %v, want the same error", got)
+	}
+
+	plain := errors.New("bad input")
+	got := From(plain, InvalidRequest)
+	if got.Code != InvalidRequest || got.Error() != plain.Error() {
+		t.Errorf("From(plain) = %s %q, want %s %q", got.Code, got.Error(), InvalidRequest, plain.Error())
+	}
+
+	if got := From(context.Canceled, InvalidRequest); got.Code != ClientClosed {
+		t.Errorf("From(context.Canceled).Code = %s, want %s", got.Code, ClientClosed)
+	}
+}
+
+func TestErrorMessage(t *testing.T) {
+	tests := []struct {
+		err  *Error
+		want string
+	}{
+		{&Error{Code: Internal, Message: "only message"}, "only message"},
+		{&Error{Code: Internal, Err: errors.New("only cause")}, "only cause"},
+		{&Error{Code: Internal, Message: "message", Err: errors.New("cause")}, "message: cause"},
+	}
+	for _, tt := range tests {
+		if got := tt.err.Error(); got != tt.want {
+			t.Errorf("Error() = %q, want %q", got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"runtime/debug"
+	"sync"
+
+	"textsurf/apperr"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 单次批量请求最多包含的提取项数
+const maxBatchSize = 1000
+
+// BatchRequest 批量提取请求，每一项的参数与 POST /fetch/{type} 相同，返回类型由 type 字段指定
+type BatchRequest struct {
+	Items []*FetchRequest `json:"items"`
+}
+
+// BatchItemResult 单个提取项的结果，成功时包含提取结果，失败时包含错误信息
+type BatchItemResult struct {
+	Index  int          `json:"index"`
+	Result *FetchResult `json:"result,omitempty"`
+	Error  gin.H        `json:"error,omitempty"`
+}
+
+// BatchResponse 批量提取结果，results 与请求中的 items 一一对应
+type BatchResponse struct {
+	Results   []*BatchItemResult `json:"results"`
+	Succeeded int                `json:"succeeded"`
+	Failed    int                `json:"failed"`
+}
+
+// handleBatchRequest 并发执行多个提取请求
+// 每一项独立校验和计时，和单个请求共享并发限制、站点限制和页面池
+func handleBatchRequest(c *gin.Context) {
+	var batch BatchRequest
+	if err := c.ShouldBindJSON(&batch); err != nil {
+		respondError(c, apperr.New(apperr.InvalidRequest, "Invalid request body: %v", err))
+		return
+	}
+	if len(batch.Items) == 0 {
+		respondError(c, apperr.New(apperr.InvalidRequest, "Missing required parameter: items"))
+		return
+	}
+	if len(batch.Items) > maxBatchSize {
+		respondError(c, apperr.New(apperr.InvalidRequest, "Too many items. At most %d items are allowed per batch", maxBatchSize))
+		return
+	}
+
+	response := &BatchResponse{Results: make([]*BatchItemResult, len(batch.Items))}
+
+	// 同时执行的项数不超过并发上限，其余项在批量请求内部等待，不占用全局等待队列
+	workers := config.MaxConcurrency
+	if workers > len(batch.Items) {
+		workers = len(batch.Items)
+	}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indexes {
+				response.Results[index] = safeBatchItem(c, index, batch.Items[index])
+			}
+		}()
+	}
+	for i := range batch.Items {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	for _, item := range response.Results {
+		if item.Error != nil {
+			response.Failed++
+		} else {
+			response.Succeeded++
+		}
+	}
+
+	if err := c.Request.Context().Err(); err != nil {
+		fmt.Printf("Client disconnected, batch of %d items aborted\n", len(batch.Items))
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// safeBatchItem 执行单个提取项，panic 时作为 internal 错误返回
+// 工作协程不在 gin 的 recovery 中间件内，未恢复的 panic 会导致整个进程退出
+func safeBatchItem(c *gin.Context, index int, req *FetchRequest) (item *BatchItemResult) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("批量提取项 panic: index=%d, error=%v\n%s", index, r, debug.Stack())
+			item = &BatchItemResult{Index: index, Error: errorBody(apperr.New(apperr.Internal, "Internal error: %v", r))}
+		}
+	}()
+	return runBatchItem(c, index, req)
+}
+
+// runBatchItem 执行单个提取项，超时从开始执行该项时计算
+func runBatchItem(c *gin.Context, index int, req *FetchRequest) *BatchItemResult {
+	item := &BatchItemResult{Index: index}
+
+	if req == nil {
+		item.Error = errorBody(apperr.New(apperr.InvalidRequest, "Item must be an object"))
+		return item
+	}
+	if err := req.validate(); err != nil {
+		item.Error = errorBody(apperr.From(err, apperr.InvalidRequest))
+		return item
+	}
+
+	ctx, cancel := requestContext(c, req.Timeout)
+	defer cancel()
+
+	// 客户端已断开时跳过剩余的项
+	if err := ctx.Err(); err != nil {
+		item.Error = errorBody(contextError(ctx, requestTimeout(req.Timeout), err))
+		return item
+	}
+
+	if err := req.loadCookies(ctx); err != nil {
+		item.Error = errorBody(apperr.From(err, apperr.InvalidRequest))
+		return item
+	}
+
+	result, err := runFetch(ctx, req)
+	if err != nil {
+		item.Error = errorBody(err)
+		return item
+	}
+
+	// 截图和 PDF 以 base64 编码放在 content 中
+	result.inlineData()
+	item.Result = result
+	return item
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"net/http"
+	"regexp"
+
+	"textsurf/apperr"
+	"textsurf/modules"
+
+	"github.com/gin-gonic/gin"
+)
+
+// qrLogin 返回模块的扫码登录接口，模块不支持时返回 unsupported
+func qrLogin(module modules.Module) (modules.QRLogin, error) {
+	if qr, ok := modules.AsQRLogin(module); ok {
+		return qr, nil
+	}
+	return nil, apperr.New(apperr.Unsupported, "Module '%s' does not support QR code login", module.Name())
+}
+
+// smsLogin 返回模块的短信登录接口，模块不支持时返回 unsupported
+func smsLogin(module modules.Module) (modules.SMSLogin, error) {
+	if sms, ok := modules.AsSMSLogin(module); ok {
+		return sms, nil
+	}
+	return nil, apperr.New(apperr.Unsupported, "Module '%s' does not support SMS login", module.Name())
+}
+
+// passwordLogin 返回模块的账号密码登录接口，模块不支持时返回 unsupported
+func passwordLogin(module modules.Module) (modules.PasswordLogin, error) {
+	if password, ok := modules.AsPasswordLogin(module); ok {
+		return password, nil
+	}
+	return nil, apperr.New(apperr.Unsupported, "Module '%s' does not support password login", module.Name())
+}
+
+// validateInput 按模块描述中的格式校验用户输入，模块未声明该输入时不校验
+func validateInput(module modules.Module, name, value string) error {
+	for _, input := range module.Metadata().Inputs {
+		if input.Name != name || input.Pattern == "" {
+			continue
+		}
+		matched, err := regexp.MatchString(input.Pattern, value)
+		if err != nil {
+			return apperr.Wrap(err, apperr.Internal, "Invalid pattern for input '%s' in module '%s'", name, module.Name())
+		}
+		if !matched {
+			return apperr.New(apperr.InvalidRequest, "Invalid %s. Expected format: %s", name, input.Pattern)
+		}
+	}
+	return nil
+}
+
+// ModuleInfo 模块信息，包含支持的登录方式和描述信息
+type ModuleInfo struct {
+	Name         string   `json:"name"`
+	Capabilities []string `json:"capabilities"`
+	modules.Metadata
+}
+
+// moduleInfo 汇总模块的登录方式和描述信息
+func moduleInfo(module modules.Module) ModuleInfo {
+	return ModuleInfo{
+		Name:         module.Name(),
+		Capabilities: modules.Capabilities(module),
+		Metadata:     module.Metadata(),
+	}
+}
+
+// handleListModules 列出所有模块及其支持的登录方式和描述信息
+func handleListModules(c *gin.Context) {
+	names := moduleRegistry.List()
+	list := make([]ModuleInfo, 0, len(names))
+	for _, name := range names {
+		module, _ := moduleRegistry.Get(name)
+		list = append(list, moduleInfo(module))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"modules": list,
+	})
+}
+
+// handleGetModule 获取单个模块的信息
+func handleGetModule(c *gin.Context) {
+	name := c.Param("name")
+	module, exists := moduleRegistry.Get(name)
+	if !exists {
+		respondError(c, apperr.New(apperr.NotFound, "Module '%s' not found", name))
+		return
+	}
+
+	c.JSON(http.StatusOK, moduleInfo(module))
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"testing"
+
+	"textsurf/modules"
+	"textsurf/modules/baichuanweb"
+)
+
+// patternModule 声明了输入格式的测试模块
+type patternModule struct {
+	modules.Module
+}
+
+func (patternModule) Name() string { return "pattern" }
+
+func (patternModule) Metadata() modules.Metadata {
+	return modules.Metadata{Inputs: []modules.Input{
+		{Name: "phone_number", Method: modules.CapabilitySMS, Pattern: `^1[3-9]\d{9}$`},
+	}}
+}
+
+func TestValidateInput(t *testing.T) {
+	tests := []struct {
+		name    string
+		module  modules.Module
+		input   string
+		value   string
+		wantErr bool
+	}{
+		{"built-in module accepts prefixed phone", baichuanweb.NewBaichuanwebModule(), "phone_number", "+86 138 0013 8000", false},
+		{"built-in module accepts any code", baichuanweb.NewBaichuanwebModule(), "sms_code", "12 34 56", false},
+		{"declared pattern accepts match", patternModule{}, "phone_number", "13800138000", false},
+		{"declared pattern rejects mismatch", patternModule{}, "phone_number", "+86 13800138000", true},
+		{"undeclared input is not checked", patternModule{}, "sms_code", "anything", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateInput(tt.module, tt.input, tt.value); (err != nil) != tt.wantErr {
+				t.Fatalf("validateInput(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"textsurf/apperr"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/proto"
+)
+
+// 常用纸张尺寸（英寸，宽 x 高）
+var paperSizes = map[string][2]float64{
+	"letter":  {8.5, 11},
+	"legal":   {8.5, 14},
+	"tabloid": {11, 17},
+	"a3":      {11.69, 16.54},
+	"a4":      {8.27, 11.69},
+	"a5":      {5.83, 8.27},
+}
+
+// 截图格式对应的 Content-Type
+var screenshotContentTypes = map[string]string{
+	"png":  "image/png",
+	"jpeg": "image/jpeg",
+	"webp": "image/webp",
+}
+
+// validateCapture 校验截图和 PDF 参数
+func (req *FetchRequest) validateCapture() error {
+	if req.Type == "screenshot" {
+		req.Format = strings.ToLower(req.Format)
+		if req.Format == "" {
+			req.Format = "png"
+		}
+		if req.Format == "jpg" {
+			req.Format = "jpeg"
+		}
+		if _, ok := screenshotContentTypes[req.Format]; !ok {
+			return fmt.Errorf("Invalid screenshot format. Use 'png', 'jpeg' or 'webp'")
+		}
+		if req.Quality < 0 || req.Quality > 100 {
+			return fmt.Errorf("Invalid quality. Must be between 0 and 100")
+		}
+		if req.FullPage && req.CSSPath != "" {
+			return fmt.Errorf("Parameters 'full_page' and 'css_path' cannot be used together")
+		}
+		return nil
+	}
+
+	req.Paper = strings.ToLower(req.Paper)
+	if req.Paper == "" {
+		req.Paper = "a4"
+	}
+	if _, ok := paperSizes[req.Paper]; !ok {
+		return fmt.Errorf("Invalid paper size. Use 'A3', 'A4', 'A5', 'Letter', 'Legal' or 'Tabloid'")
+	}
+	for name, value := range map[string]string{
+		"margin":        req.Margin,
+		"margin_top":    req.MarginTop,
+		"margin_right":  req.MarginRight,
+		"margin_bottom": req.MarginBottom,
+		"margin_left":   req.MarginLeft,
+	} {
+		if _, err := parseLength(value); err != nil {
+			return fmt.Errorf("Invalid %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// captureScreenshot 截取整页、可视区域或指定元素
+func captureScreenshot(page *rod.Page, req *FetchRequest, result *FetchResult) (*FetchResult, error) {
+	format := proto.PageCaptureScreenshotFormat(req.Format)
+
+	quality := req.Quality
+	if quality == 0 {
+		quality = 90
+	}
+
+	var data []byte
+	var err error
+	if req.CSSPath != "" {
+		element, findErr := findElement(page, req.CSSPath)
+		if findErr != nil {
+			return nil, findErr
+		}
+		data, err = element.Screenshot(format, quality)
+	} else {
+		// png 不支持 quality 参数
+		opts := &proto.PageCaptureScreenshot{Format: format}
+		if format != proto.PageCaptureScreenshotFormatPng {
+			opts.Quality = &quality
+		}
+		data, err = page.Screenshot(req.FullPage, opts)
+	}
+	if err != nil {
+		return nil, apperr.Wrap(err, apperr.Internal, "Error capturing screenshot")
+	}
+
+	result.Data = data
+	result.ContentType = screenshotContentTypes[req.Format]
+	return result, nil
+}
+
+// printPDF 将页面打印为 PDF
+func printPDF(page *rod.Page, req *FetchRequest, result *FetchResult) (*FetchResult, error) {
+	size := paperSizes[req.Paper]
+	width, height := size[0], size[1]
+
+	// 统一边距在前，单边设置覆盖统一边距
+	margins := [4]*float64{}
+	if margin, _ := parseLength(req.Margin); margin != nil {
+		for i := range margins {
+			margins[i] = margin
+		}
+	}
+	for i, value := range []string{req.MarginTop, req.MarginRight, req.MarginBottom, req.MarginLeft} {
+		if margin, _ := parseLength(value); margin != nil {
+			margins[i] = margin
+		}
+	}
+
+	stream, err := page.PDF(&proto.PagePrintToPDF{
+		Landscape:       req.Landscape,
+		PrintBackground: req.PrintBackground,
+		PaperWidth:      &width,
+		PaperHeight:     &height,
+		MarginTop:       margins[0],
+		MarginRight:     margins[1],
+		MarginBottom:    margins[2],
+		MarginLeft:      margins[3],
+	})
+	if err != nil {
+		return nil, apperr.Wrap(err, apperr.Internal, "Error printing PDF")
+	}
+
+	data, err := io.ReadAll(stream)
+	if err != nil {
+		return nil, apperr.Wrap(err, apperr.Internal, "Error reading PDF stream")
+	}
+
+	result.Data = data
+	result.ContentType = "application/pdf"
+	return result, nil
+}
+
+// parseLength 将带单位的长度转换为英寸，空字符串返回 nil
+func parseLength(value string) (*float64, error) {
+	value = strings.TrimSpace(strings.ToLower(value))
+	if value == "" {
+		return nil, nil
+	}
+
+	units := []struct {
+		suffix  string
+		perInch float64
+	}{
+		{"in", 1},
+		{"cm", 2.54},
+		{"mm", 25.4},
+		{"px", 96},
+	}
+
+	perInch := 1.0
+	for _, unit := range units {
+		if strings.HasSuffix(value, unit.suffix) {
+			value = strings.TrimSpace(strings.TrimSuffix(value, unit.suffix))
+			perInch = unit.perInch
+			break
+		}
+	}
+
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil || number < 0 {
+		return nil, fmt.Errorf("must be a non-negative length such as '0.5in', '1cm' or '10mm'")
+	}
+
+	inches := number / perInch
+	return &inches, nil
+}
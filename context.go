@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strconv"
+	"time"
+
+	"textsurf/apperr"
+	"textsurf/modules"
+
+	"github.com/gin-gonic/gin"
+)
+
+// errInvalidTimeout timeout 参数无效
+var errInvalidTimeout = apperr.New(apperr.InvalidRequest, "Invalid timeout. Must be a non-negative number of seconds")
+
+// requestTimeout 将超时秒数转换为时长，未指定时使用服务默认超时
+func requestTimeout(seconds float64) time.Duration {
+	if seconds > 0 {
+		return time.Duration(seconds * float64(time.Second))
+	}
+	return config.Timeout
+}
+
+// requestContext 创建绑定客户端连接和超时的上下文
+// 客户端断开或超时后，绑定该上下文的页面操作会被中断
+func requestContext(c *gin.Context, seconds float64) (context.Context, context.CancelFunc) {
+	return withRequestTimeout(c.Request.Context(), seconds)
+}
+
+// withRequestTimeout 为 parent 附加请求超时，超时为 0 时只能取消
+func withRequestTimeout(parent context.Context, seconds float64) (context.Context, context.CancelFunc) {
+	timeout := requestTimeout(seconds)
+	if timeout <= 0 {
+		return context.WithCancel(parent)
+	}
+	return context.WithTimeout(parent, timeout)
+}
+
+// queryTimeout 读取 query 中的 timeout 秒数
+func queryTimeout(c *gin.Context) (float64, error) {
+	value := c.Query("timeout")
+	if value == "" {
+		return 0, nil
+	}
+	seconds, err := strconv.ParseFloat(value, 64)
+	if err != nil || seconds < 0 {
+		return 0, errInvalidTimeout
+	}
+	return seconds, nil
+}
+
+// contextError 把超时和客户端断开的错误转换为对应的请求错误
+// 模块返回的错误通常不保留错误链，所以同时检查上下文状态
+func contextError(ctx context.Context, timeout time.Duration, err error) error {
+	if err == nil {
+		return nil
+	}
+	code := apperr.CodeOf(err)
+	switch {
+	case code == apperr.Timeout,
+		code == apperr.Internal && errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return apperr.Wrap(err, apperr.Timeout, "Request timed out after %v", timeout)
+	case code == apperr.ClientClosed,
+		code == apperr.Internal && errors.Is(ctx.Err(), context.Canceled):
+		return apperr.Wrap(err, apperr.ClientClosed, "Client closed request")
+	}
+	return err
+}
+
+// bindSession 返回浏览器和页面绑定到 ctx 的会话副本
+// 会话页面创建后不再替换，副本不需要写回原会话，登录监听可以同时读取原会话
+func bindSession(ctx context.Context, session *modules.Session) *modules.Session {
+	bound := *session
+	if session.Browser != nil {
+		bound.Browser = session.Browser.Context(ctx)
+	}
+	if session.Page != nil {
+		bound.Page = session.Page.Context(ctx)
+	}
+	return &bound
+}
+
+// runModule 在请求上下文内执行模块操作，超时或客户端断开后中断页面操作
+func runModule(c *gin.Context, session *modules.Session, fn func(session *modules.Session) error) error {
+	seconds, err := queryTimeout(c)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := requestContext(c, seconds)
+	defer cancel()
+
+	err = fn(bindSession(ctx, session))
+
+	err = contextError(ctx, requestTimeout(seconds), err)
+	if apperr.CodeOf(err) == apperr.ClientClosed {
+		log.Printf("客户端已断开连接: module=%s, session_id=%s\n", session.Module.Name(), session.ID)
+	}
+	return err
+}
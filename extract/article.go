@@ -0,0 +1,575 @@
+package extract
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+var (
+	// 类名或 id 命中时基本可以确定不是正文
+	unlikelyRegexp = regexp.MustCompile(`(?i)banner|breadcrumb|combx|comment|community|consent|cookie|disqus|extra|foot|gdpr|header|legends|menu|modal|nav|newsletter|pager|pagination|popup|promo|related|remark|replies|rss|share|shoutbox|sidebar|skyscraper|social|sponsor|subscribe|tool|widget|advert|\bads?\b|ad-break`)
+	// 可能是正文的类名或 id，用于抵消 unlikelyRegexp 的误判
+	maybeRegexp = regexp.MustCompile(`(?i)and|article|body|column|content|main|shadow`)
+	// 类名或 id 的正向、负向权重
+	positiveRegexp = regexp.MustCompile(`(?i)article|body|content|entry|hentry|h-entry|main|page|pagination|post|text|blog|story`)
+	negativeRegexp = regexp.MustCompile(`(?i)-ad-|hidden|^hid$|\bhid\b|banner|combx|comment|com-|contact|foot|footer|footnote|gdpr|masthead|media|meta|outbrain|promo|related|scroll|share|shoutbox|sidebar|skyscraper|sponsor|shopping|tags|tool|widget`)
+	bylineRegexp   = regexp.MustCompile(`(?i)byline|author|dateline|writtenby|p-author`)
+	commaRegexp    = regexp.MustCompile(`[,，、;；]`)
+)
+
+// 正文中需要整体移除的标签
+var articleJunkTags = map[string]bool{
+	"script":   true,
+	"style":    true,
+	"noscript": true,
+	"template": true,
+	"iframe":   true,
+	"form":     true,
+	"button":   true,
+	"input":    true,
+	"select":   true,
+	"textarea": true,
+	"nav":      true,
+	"footer":   true,
+	"aside":    true,
+	"svg":      true,
+	"canvas":   true,
+}
+
+// Article 正文提取结果
+type Article struct {
+	Title     string `json:"title"`
+	Byline    string `json:"byline"`
+	Published string `json:"published_at"`
+	LeadImage string `json:"lead_image"`
+	// Content 正文 HTML
+	Content string `json:"-"`
+	// Text 正文纯文本
+	Text string `json:"-"`
+}
+
+// ExtractArticle 从整页 HTML 中提取正文及标题、作者、发布时间和头图
+// 对 DOM 块按段落文本量、逗号数量、类名和链接密度打分，取得分最高的块及其相关兄弟节点
+func ExtractArticle(src string, baseURL string) (*Article, error) {
+	doc, err := html.Parse(strings.NewReader(src))
+	if err != nil {
+		return nil, fmt.Errorf("解析HTML失败: %v", err)
+	}
+
+	article := &Article{}
+	readMetadata(doc, article)
+
+	removeUnlikely(doc)
+	top := findTopCandidate(doc)
+	if top == nil {
+		return nil, fmt.Errorf("未找到正文内容")
+	}
+
+	content := collectContent(top)
+	cleanContent(content)
+
+	if article.Title == "" {
+		if h1 := findFirst(content, "h1"); h1 != nil {
+			article.Title = normalizeSpace(textContent(h1))
+		}
+	}
+	if article.LeadImage == "" {
+		if img := findFirst(content, "img"); img != nil {
+			article.LeadImage, _ = attr(img, "src")
+		}
+	}
+
+	var b strings.Builder
+	if err := html.Render(&b, content); err != nil {
+		return nil, fmt.Errorf("渲染正文失败: %v", err)
+	}
+	article.Content = b.String()
+	article.Text = blockText(content)
+
+	if article.LeadImage != "" {
+		article.LeadImage = newMarkdownConverter(baseURL).resolve(article.LeadImage)
+	}
+
+	return article, nil
+}
+
+// readMetadata 从 meta 标签、JSON-LD 和页面元素中读取文章元信息
+func readMetadata(doc *html.Node, article *Article) {
+	meta := make(map[string]string)
+	var ldScripts []string
+	var titleTag string
+
+	walk(doc, func(n *html.Node) bool {
+		switch n.Data {
+		case "meta":
+			key, _ := attr(n, "property")
+			if key == "" {
+				key, _ = attr(n, "name")
+			}
+			if key == "" {
+				key, _ = attr(n, "itemprop")
+			}
+			value, _ := attr(n, "content")
+			key = strings.ToLower(strings.TrimSpace(key))
+			if key != "" && value != "" {
+				if _, exists := meta[key]; !exists {
+					meta[key] = strings.TrimSpace(value)
+				}
+			}
+		case "title":
+			if titleTag == "" {
+				titleTag = normalizeSpace(textContent(n))
+			}
+		case "script":
+			if typ, _ := attr(n, "type"); strings.EqualFold(typ, "application/ld+json") {
+				ldScripts = append(ldScripts, textContent(n))
+			}
+		}
+		return true
+	})
+
+	ld := readJSONLD(ldScripts)
+
+	article.Title = firstNonEmpty(meta["og:title"], meta["twitter:title"], ld["headline"], titleTag)
+	article.Byline = firstNonEmpty(meta["author"], meta["article:author"], meta["byl"], ld["author"])
+	article.Published = firstNonEmpty(meta["article:published_time"], meta["og:published_time"],
+		meta["datepublished"], meta["pubdate"], meta["publishdate"], meta["date"], ld["datePublished"])
+	article.LeadImage = firstNonEmpty(meta["og:image"], meta["og:image:url"], meta["twitter:image"], ld["image"])
+
+	// meta 中没有作者和时间时从页面元素中查找
+	walk(doc, func(n *html.Node) bool {
+		if article.Byline == "" {
+			rel, _ := attr(n, "rel")
+			itemprop, _ := attr(n, "itemprop")
+			if rel == "author" || strings.Contains(itemprop, "author") || bylineRegexp.MatchString(classAndID(n)) {
+				if text := normalizeSpace(textContent(n)); text != "" && len([]rune(text)) < 100 {
+					article.Byline = text
+				}
+			}
+		}
+		if article.Published == "" && n.Data == "time" {
+			if datetime, ok := attr(n, "datetime"); ok && datetime != "" {
+				article.Published = datetime
+			} else {
+				article.Published = normalizeSpace(textContent(n))
+			}
+		}
+		return article.Byline == "" || article.Published == ""
+	})
+}
+
+// readJSONLD 从 JSON-LD 中读取 Article 类型对象的字段
+func readJSONLD(scripts []string) map[string]string {
+	result := make(map[string]string)
+
+	var visit func(v interface{})
+	visit = func(v interface{}) {
+		switch obj := v.(type) {
+		case []interface{}:
+			for _, item := range obj {
+				visit(item)
+			}
+		case map[string]interface{}:
+			if graph, ok := obj["@graph"]; ok {
+				visit(graph)
+			}
+			typ := fmt.Sprint(obj["@type"])
+			if !strings.Contains(typ, "Article") && !strings.Contains(typ, "BlogPosting") && !strings.Contains(typ, "Report") {
+				return
+			}
+			for _, key := range []string{"headline", "datePublished", "author", "image"} {
+				if _, exists := result[key]; !exists {
+					if value := jsonLDString(obj[key]); value != "" {
+						result[key] = value
+					}
+				}
+			}
+		}
+	}
+
+	for _, script := range scripts {
+		var v interface{}
+		if err := json.Unmarshal([]byte(script), &v); err == nil {
+			visit(v)
+		}
+	}
+	return result
+}
+
+// jsonLDString 将 JSON-LD 字段值统一转换为字符串
+func jsonLDString(v interface{}) string {
+	switch value := v.(type) {
+	case string:
+		return strings.TrimSpace(value)
+	case []interface{}:
+		var parts []string
+		for _, item := range value {
+			if s := jsonLDString(item); s != "" {
+				parts = append(parts, s)
+			}
+		}
+		return strings.Join(parts, ", ")
+	case map[string]interface{}:
+		for _, key := range []string{"name", "url", "@id"} {
+			if s, ok := value[key].(string); ok && s != "" {
+				return strings.TrimSpace(s)
+			}
+		}
+	}
+	return ""
+}
+
+// removeUnlikely 移除明显不是正文的节点
+func removeUnlikely(doc *html.Node) {
+	var junk []*html.Node
+	walk(doc, func(n *html.Node) bool {
+		switch n.Data {
+		case "html", "body", "article", "main":
+			return true
+		}
+		if articleJunkTags[n.Data] || n.Data == "header" {
+			junk = append(junk, n)
+			return false
+		}
+		if role, _ := attr(n, "role"); role == "navigation" || role == "banner" || role == "complementary" || role == "dialog" {
+			junk = append(junk, n)
+			return false
+		}
+		if _, hidden := attr(n, "hidden"); hidden {
+			junk = append(junk, n)
+			return false
+		}
+		if match := classAndID(n); unlikelyRegexp.MatchString(match) && !maybeRegexp.MatchString(match) {
+			junk = append(junk, n)
+			return false
+		}
+		return true
+	})
+
+	for _, n := range junk {
+		if n.Parent != nil {
+			n.Parent.RemoveChild(n)
+		}
+	}
+}
+
+// findTopCandidate 对段落打分并返回得分最高的容器
+func findTopCandidate(doc *html.Node) *html.Node {
+	scores := make(map[*html.Node]float64)
+	var candidates []*html.Node
+
+	addScore := func(n *html.Node, score float64) {
+		if n == nil || n.Type != html.ElementNode || n.Data == "html" {
+			return
+		}
+		if _, exists := scores[n]; !exists {
+			scores[n] = initialScore(n)
+			candidates = append(candidates, n)
+		}
+		scores[n] += score
+	}
+
+	walk(doc, func(n *html.Node) bool {
+		switch n.Data {
+		case "p", "pre", "td", "blockquote", "section", "li":
+		default:
+			return true
+		}
+
+		text := normalizeSpace(textContent(n))
+		length := len([]rune(text))
+		if length < 25 {
+			return true
+		}
+
+		score := 1 + float64(len(commaRegexp.FindAllString(text, -1)))
+		score += math.Min(float64(length)/100, 3)
+
+		addScore(n.Parent, score)
+		if n.Parent != nil {
+			addScore(n.Parent.Parent, score/2)
+			if n.Parent.Parent != nil {
+				addScore(n.Parent.Parent.Parent, score/3)
+			}
+		}
+		return true
+	})
+
+	var top *html.Node
+	best := 0.0
+	for _, n := range candidates {
+		score := scores[n] * (1 - linkDensity(n))
+		scores[n] = score
+		if top == nil || score > best {
+			top = n
+			best = score
+		}
+	}
+
+	if top == nil {
+		return findFirst(doc, "body")
+	}
+
+	// 得分分散在多个兄弟节点中时向上取公共父节点
+	for parent := top.Parent; parent != nil && parent.Data != "body" && parent.Data != "html"; parent = parent.Parent {
+		if scores[parent] < best*0.75 {
+			break
+		}
+		top = parent
+		best = scores[parent]
+	}
+
+	return top
+}
+
+// collectContent 把得分最高的节点与得分接近的兄弟节点合并为正文容器
+func collectContent(top *html.Node) *html.Node {
+	container := &html.Node{Type: html.ElementNode, Data: "div"}
+	if top.Parent == nil || top.Data == "body" {
+		moveChildren(top, container)
+		return container
+	}
+
+	topScore := paragraphScore(top)
+	threshold := math.Max(10, topScore*0.2)
+	topClass, _ := attr(top, "class")
+
+	var siblings []*html.Node
+	for sibling := top.Parent.FirstChild; sibling != nil; sibling = sibling.NextSibling {
+		siblings = append(siblings, sibling)
+	}
+
+	for _, sibling := range siblings {
+		if sibling == top {
+			top.Parent.RemoveChild(top)
+			container.AppendChild(top)
+			continue
+		}
+		if sibling.Type != html.ElementNode {
+			continue
+		}
+
+		keep := false
+		bonus := 0.0
+		if class, _ := attr(sibling, "class"); class != "" && class == topClass {
+			bonus = topScore * 0.2
+		}
+		if paragraphScore(sibling)+bonus >= threshold {
+			keep = true
+		} else if sibling.Data == "p" {
+			text := normalizeSpace(textContent(sibling))
+			density := linkDensity(sibling)
+			length := len([]rune(text))
+			if (length > 80 && density < 0.25) || (length > 0 && length <= 80 && density == 0 && strings.ContainsAny(text, ".。")) {
+				keep = true
+			}
+		}
+
+		if keep {
+			sibling.Parent.RemoveChild(sibling)
+			container.AppendChild(sibling)
+		}
+	}
+
+	return container
+}
+
+// cleanContent 清理正文中残留的无关节点
+func cleanContent(content *html.Node) {
+	var junk []*html.Node
+	walk(content, func(n *html.Node) bool {
+		if n == content {
+			return true
+		}
+		if articleJunkTags[n.Data] {
+			junk = append(junk, n)
+			return false
+		}
+		if negativeRegexp.MatchString(classAndID(n)) && !positiveRegexp.MatchString(classAndID(n)) && linkDensity(n) > 0.3 {
+			junk = append(junk, n)
+			return false
+		}
+		return true
+	})
+
+	for _, n := range junk {
+		if n.Parent != nil {
+			n.Parent.RemoveChild(n)
+		}
+	}
+}
+
+// initialScore 根据标签和类名给出初始分
+func initialScore(n *html.Node) float64 {
+	score := 0.0
+	switch n.Data {
+	case "article", "main":
+		score += 10
+	case "div":
+		score += 5
+	case "pre", "td", "blockquote":
+		score += 3
+	case "address", "ol", "ul", "dl", "dd", "dt", "li", "form":
+		score -= 3
+	case "h1", "h2", "h3", "h4", "h5", "h6", "th":
+		score -= 5
+	}
+
+	match := classAndID(n)
+	if match != "" {
+		if negativeRegexp.MatchString(match) {
+			score -= 25
+		}
+		if positiveRegexp.MatchString(match) {
+			score += 25
+		}
+	}
+	return score
+}
+
+// paragraphScore 估算节点内段落文本的得分，用于兄弟节点比较
+func paragraphScore(n *html.Node) float64 {
+	score := 0.0
+	walk(n, func(node *html.Node) bool {
+		if node.Data != "p" && node.Data != "pre" && node.Data != "blockquote" {
+			return true
+		}
+		text := normalizeSpace(textContent(node))
+		if length := len([]rune(text)); length >= 25 {
+			score += 1 + float64(len(commaRegexp.FindAllString(text, -1))) + math.Min(float64(length)/100, 3)
+		}
+		return false
+	})
+	return score * (1 - linkDensity(n))
+}
+
+// linkDensity 链接文本占全部文本的比例
+func linkDensity(n *html.Node) float64 {
+	total := len([]rune(normalizeSpace(textContent(n))))
+	if total == 0 {
+		return 0
+	}
+
+	links := 0
+	walk(n, func(node *html.Node) bool {
+		if node.Data == "a" {
+			links += len([]rune(normalizeSpace(textContent(node))))
+			return false
+		}
+		return true
+	})
+	return float64(links) / float64(total)
+}
+
+// blockText 将正文转为纯文本，块级元素之间换行
+func blockText(n *html.Node) string {
+	var b strings.Builder
+	var visit func(*html.Node)
+	visit = func(node *html.Node) {
+		switch node.Type {
+		case html.TextNode:
+			b.WriteString(spaceRegexp.ReplaceAllString(node.Data, " "))
+			return
+		case html.ElementNode:
+			if node.Data == "br" {
+				b.WriteString("\n")
+				return
+			}
+		}
+
+		block := node.Type == html.ElementNode && isBlock(node.Data)
+		if block {
+			b.WriteString("\n")
+		}
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			visit(child)
+		}
+		if block {
+			b.WriteString("\n")
+		}
+	}
+	visit(n)
+
+	return strings.Join(trimLines(b.String()), "\n")
+}
+
+// walk 先序遍历元素节点，fn 返回 false 时不再进入子节点
+func walk(n *html.Node, fn func(*html.Node) bool) {
+	if n.Type == html.ElementNode && !fn(n) {
+		return
+	}
+	for child := n.FirstChild; child != nil; {
+		// 遍历过程中节点可能被移动，提前记录下一个兄弟节点
+		next := child.NextSibling
+		walk(child, fn)
+		child = next
+	}
+}
+
+// findFirst 查找第一个指定标签的元素
+func findFirst(n *html.Node, tag string) *html.Node {
+	var found *html.Node
+	walk(n, func(node *html.Node) bool {
+		if found != nil {
+			return false
+		}
+		if node.Data == tag {
+			found = node
+			return false
+		}
+		return true
+	})
+	return found
+}
+
+// moveChildren 将 from 的所有子节点移动到 to 下
+func moveChildren(from, to *html.Node) {
+	for child := from.FirstChild; child != nil; {
+		next := child.NextSibling
+		from.RemoveChild(child)
+		to.AppendChild(child)
+		child = next
+	}
+}
+
+// textContent 获取节点下全部文本
+func textContent(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var b strings.Builder
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.ElementNode && (child.Data == "script" || child.Data == "style") {
+			continue
+		}
+		b.WriteString(textContent(child))
+	}
+	return b.String()
+}
+
+// classAndID 拼接 class 和 id 用于正则匹配
+func classAndID(n *html.Node) string {
+	class, _ := attr(n, "class")
+	id, _ := attr(n, "id")
+	return strings.TrimSpace(class + " " + id)
+}
+
+// normalizeSpace 合并连续空白
+func normalizeSpace(s string) string {
+	return strings.TrimSpace(spaceRegexp.ReplaceAllString(s, " "))
+}
+
+// firstNonEmpty 返回第一个非空字符串
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
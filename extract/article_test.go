@@ -0,0 +1,167 @@
+package extract
+
+import (
+	"strings"
+	"testing"
+)
+
+// 足够长、带逗号的段落，会被计入正文得分
+const storyParagraph = "The city council met on Tuesday, and after a long debate, voted to expand the riverside park by twelve acres."
+
+func TestExtractArticleContent(t *testing.T) {
+	tests := []struct {
+		name    string
+		page    string
+		want    []string
+		notWant []string
+	}{
+		{
+			name: "picks the main article over the sidebar",
+			page: `<body>
+				<div class="sidebar"><p>Sidebar teaser one, short, with commas, and some words to pass the length check.</p></div>
+				<div id="story"><p>` + storyParagraph + `</p><p>` + storyParagraph + `</p><p>` + storyParagraph + `</p></div>
+			</body>`,
+			want:    []string{"riverside park"},
+			notWant: []string{"Sidebar teaser"},
+		},
+		{
+			name: "removes navigation, scripts and footers",
+			page: `<body>
+				<header><a href="/">Site name</a></header>
+				<nav><a href="/news">News</a><a href="/sports">Sports</a></nav>
+				<article>
+					<p>` + storyParagraph + `</p>
+					<script>trackReader()</script>
+					<div class="share-buttons"><a href="#">Share on social</a></div>
+					<p>` + storyParagraph + `</p>
+				</article>
+				<footer>Copyright notice</footer>
+			</body>`,
+			want:    []string{"riverside park"},
+			notWant: []string{"Site name", "Sports", "trackReader", "Share on social", "Copyright"},
+		},
+		{
+			name: "drops link lists inside the article",
+			page: `<body><div class="content">
+				<p>` + storyParagraph + `</p>
+				<div class="related-tags"><a href="/a">Related link one</a> <a href="/b">Related link two</a></div>
+				<p>` + storyParagraph + `</p>
+			</div></body>`,
+			want:    []string{"riverside park"},
+			notWant: []string{"Related link"},
+		},
+		{
+			name:    "hidden elements are removed",
+			page:    `<body><main><p hidden>Hidden cookie banner text</p><p>` + storyParagraph + `</p></main></body>`,
+			want:    []string{"riverside park"},
+			notWant: []string{"Hidden cookie banner"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			article, err := ExtractArticle("<html>"+tt.page+"</html>", "https://example.com/news/")
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(article.Text, want) {
+					t.Errorf("Text = %q, want it to contain %q", article.Text, want)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(article.Text, notWant) || strings.Contains(article.Content, notWant) {
+					t.Errorf("article contains boilerplate %q: %q", notWant, article.Text)
+				}
+			}
+		})
+	}
+}
+
+func TestExtractArticleMetadata(t *testing.T) {
+	body := `<body><article><p>` + storyParagraph + `</p></article></body>`
+	tests := []struct {
+		name string
+		head string
+		body string
+		want Article
+	}{
+		{
+			name: "open graph and meta tags",
+			head: `<title>Page title</title>
+				<meta property="og:title" content="Park expansion approved">
+				<meta name="author" content="Jane Doe">
+				<meta property="article:published_time" content="2024-05-01T10:00:00Z">
+				<meta property="og:image" content="/img/park.jpg">`,
+			body: body,
+			want: Article{
+				Title:     "Park expansion approved",
+				Byline:    "Jane Doe",
+				Published: "2024-05-01T10:00:00Z",
+				LeadImage: "https://example.com/img/park.jpg",
+			},
+		},
+		{
+			name: "json-ld fallback",
+			head: `<script type="application/ld+json">
+				{"@type": "NewsArticle", "headline": "From JSON-LD", "author": {"name": "Li Lei"}, "datePublished": "2024-06-01"}
+				</script>`,
+			body: body,
+			want: Article{Title: "From JSON-LD", Byline: "Li Lei", Published: "2024-06-01"},
+		},
+		{
+			name: "page elements fallback",
+			head: `<title>Title tag</title>`,
+			body: `<body><article>
+				<span class="byline">By Alex</span><time datetime="2024-07-01">July 1</time>
+				<img src="lead.png"><p>` + storyParagraph + `</p>
+			</article></body>`,
+			want: Article{Title: "Title tag", Byline: "By Alex", Published: "2024-07-01", LeadImage: "https://example.com/news/lead.png"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			article, err := ExtractArticle("<html><head>"+tt.head+"</head>"+tt.body+"</html>", "https://example.com/news/")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := Article{Title: article.Title, Byline: article.Byline, Published: article.Published, LeadImage: article.LeadImage}
+			if got != tt.want {
+				t.Errorf("metadata = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestArticleText(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{
+			name: "paragraphs",
+			body: `<p>First paragraph of the story, long enough to count as content, with commas, and more.</p>` +
+				`<p>Second paragraph of the story, long enough to count as content, with commas, and more.</p>`,
+			want: "First paragraph of the story, long enough to count as content, with commas, and more.\n" +
+				"Second paragraph of the story, long enough to count as content, with commas, and more.",
+		},
+		{
+			name: "br and markdown characters stay plain",
+			body: `<p>Line one of a *long* paragraph, with commas, and more text here<br>line_two [kept] as is, with commas, and more.</p>`,
+			want: "Line one of a *long* paragraph, with commas, and more text here\n" +
+				"line_two [kept] as is, with commas, and more.",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := `<html><body><div class="article-content">` + tt.body + `</div></body></html>`
+			article, err := ExtractArticle(src, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if article.Text != tt.want {
+				t.Errorf("Text = %q, want %q", article.Text, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,545 @@
+package extract
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+var (
+	spaceRegexp   = regexp.MustCompile(`[ \t\r\n\f]+`)
+	newlineRegexp = regexp.MustCompile(`\n{3,}`)
+	// 片段开头的第一个标签，跳过前面的空白和注释
+	firstTagRegexp = regexp.MustCompile(`^\s*(?:<!--[\s\S]*?-->\s*)*<(!?[a-zA-Z][a-zA-Z0-9-]*)`)
+	// 行首会被当作有序列表的编号，例如 "1. " 和 "2024) "
+	orderedMarkerRegexp = regexp.MustCompile(`^(\d{1,9})([.)])(\s|$)`)
+	// 行首会被当作标题、无序列表或引用的标记
+	blockMarkerRegexp = regexp.MustCompile(`^(#|[-+](\s|$)|>)`)
+)
+
+// 文本中需要转义的 Markdown 标记
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	"`", "\\`",
+	`*`, `\*`,
+	`_`, `\_`,
+	`[`, `\[`,
+	`]`, `\]`,
+)
+
+// 片段以这些标签开头时需要的上下文元素，由外到内排列，其他片段按 body 的内容解析
+var fragmentContexts = map[string][]atom.Atom{
+	"caption":  {atom.Table},
+	"colgroup": {atom.Table},
+	"col":      {atom.Table, atom.Colgroup},
+	"thead":    {atom.Table},
+	"tbody":    {atom.Table},
+	"tfoot":    {atom.Table},
+	"tr":       {atom.Table, atom.Tbody},
+	"td":       {atom.Table, atom.Tbody, atom.Tr},
+	"th":       {atom.Table, atom.Tbody, atom.Tr},
+	"li":       {atom.Ul},
+	"dt":       {atom.Dl},
+	"dd":       {atom.Dl},
+}
+
+// 转换时直接丢弃的标签
+var skippedTags = map[string]bool{
+	"script":   true,
+	"style":    true,
+	"noscript": true,
+	"template": true,
+	"iframe":   true,
+	"svg":      true,
+	"canvas":   true,
+	"head":     true,
+	"select":   true,
+	"option":   true,
+}
+
+// 作为普通块容器处理的标签
+var containerTags = map[string]bool{
+	"html":       true,
+	"body":       true,
+	"div":        true,
+	"section":    true,
+	"article":    true,
+	"main":       true,
+	"header":     true,
+	"footer":     true,
+	"nav":        true,
+	"aside":      true,
+	"figure":     true,
+	"figcaption": true,
+	"form":       true,
+	"fieldset":   true,
+	"details":    true,
+	"summary":    true,
+	"address":    true,
+	"dl":         true,
+	"dt":         true,
+	"dd":         true,
+	"center":     true,
+}
+
+// HTMLToMarkdown 将 HTML 片段或完整文档转换为 Markdown
+// baseURL 用于把链接和图片的相对地址补全为绝对地址，可以为空
+func HTMLToMarkdown(src string, baseURL string) (string, error) {
+	doc, err := parseHTML(src)
+	if err != nil {
+		return "", fmt.Errorf("解析HTML失败: %v", err)
+	}
+
+	return newMarkdownConverter(baseURL).convert(doc), nil
+}
+
+// parseHTML 解析 HTML，完整文档按文档解析，片段按第一个标签需要的上下文解析
+// 例如 tr、td、li 片段分别放在表格和列表中，避免解析时丢失结构
+func parseHTML(src string) (*html.Node, error) {
+	tag := ""
+	if m := firstTagRegexp.FindStringSubmatch(src); m != nil {
+		tag = strings.ToLower(m[1])
+	}
+	switch tag {
+	case "!doctype", "html", "head", "body":
+		return html.Parse(strings.NewReader(src))
+	}
+
+	root := &html.Node{Type: html.DocumentNode}
+	parent := root
+	for _, a := range fragmentContexts[tag] {
+		el := &html.Node{Type: html.ElementNode, DataAtom: a, Data: a.String()}
+		parent.AppendChild(el)
+		parent = el
+	}
+
+	context := parent
+	if parent == root {
+		context = &html.Node{Type: html.ElementNode, DataAtom: atom.Body, Data: atom.Body.String()}
+	}
+	nodes, err := html.ParseFragment(strings.NewReader(src), context)
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range nodes {
+		parent.AppendChild(n)
+	}
+	return root, nil
+}
+
+// markdownConverter HTML 到 Markdown 的转换器
+type markdownConverter struct {
+	base *url.URL
+}
+
+// newMarkdownConverter 创建转换器，baseURL 无法解析时不补全相对地址
+func newMarkdownConverter(baseURL string) *markdownConverter {
+	c := &markdownConverter{}
+	if baseURL != "" {
+		if base, err := url.Parse(baseURL); err == nil {
+			c.base = base
+		}
+	}
+	return c
+}
+
+// convert 转换节点的子节点并规范化空行
+func (c *markdownConverter) convert(n *html.Node) string {
+	out := c.blocks(n)
+	out = newlineRegexp.ReplaceAllString(out, "\n\n")
+	return strings.TrimSpace(out)
+}
+
+// blocks 将子节点渲染为以空行分隔的块序列
+func (c *markdownConverter) blocks(n *html.Node) string {
+	var parts []string
+	var inline strings.Builder
+
+	flush := func() {
+		if text := cleanInline(inline.String()); text != "" {
+			parts = append(parts, text)
+		}
+		inline.Reset()
+	}
+
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type != html.ElementNode || !isBlock(child.Data) {
+			inline.WriteString(c.inline(child))
+			continue
+		}
+
+		flush()
+		block := c.block(child)
+		switch {
+		case block == "":
+		case n.Data == "li" && (child.Data == "ul" || child.Data == "ol") && len(parts) > 0:
+			// 列表项中的子列表紧跟在上一块后面，保持紧凑列表
+			parts[len(parts)-1] += "\n" + block
+		default:
+			parts = append(parts, block)
+		}
+	}
+	flush()
+
+	return strings.Join(parts, "\n\n")
+}
+
+// block 渲染单个块级元素
+func (c *markdownConverter) block(n *html.Node) string {
+	switch n.Data {
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		text := cleanInline(spaceRegexp.ReplaceAllString(c.inlineChildren(n), " "))
+		if text == "" {
+			return ""
+		}
+		level := int(n.Data[1] - '0')
+		return strings.Repeat("#", level) + " " + text
+	case "p":
+		return cleanInline(c.inlineChildren(n))
+	case "ul", "ol":
+		return c.list(n, n.Data == "ol")
+	case "li":
+		// 脱离列表的 li 按无序列表项处理
+		if content := c.convert(n); content != "" {
+			return prefixLines(content, "- ", "  ")
+		}
+		return ""
+	case "pre":
+		return c.codeBlock(n)
+	case "blockquote":
+		inner := c.convert(n)
+		if inner == "" {
+			return ""
+		}
+		return prefixLines(inner, "> ", "> ")
+	case "table":
+		return c.table(n)
+	case "hr":
+		return "---"
+	}
+
+	if skippedTags[n.Data] {
+		return ""
+	}
+	return c.convert(n)
+}
+
+// list 渲染有序或无序列表，支持嵌套
+func (c *markdownConverter) list(n *html.Node, ordered bool) string {
+	var items []string
+	index := 1
+	if start, ok := attr(n, "start"); ok {
+		fmt.Sscanf(start, "%d", &index)
+	}
+
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type != html.ElementNode {
+			continue
+		}
+
+		var content string
+		switch child.Data {
+		case "li":
+			content = c.convert(child)
+		case "ul", "ol":
+			// 不规范的嵌套列表直接挂在上一项下面
+			nested := c.list(child, child.Data == "ol")
+			if nested != "" && len(items) > 0 {
+				items[len(items)-1] += "\n" + prefixLines(nested, "  ", "  ")
+			}
+			continue
+		default:
+			continue
+		}
+
+		marker := "- "
+		if ordered {
+			marker = fmt.Sprintf("%d. ", index)
+			index++
+		}
+		items = append(items, prefixLines(content, marker, strings.Repeat(" ", len(marker))))
+	}
+
+	return strings.Join(items, "\n")
+}
+
+// codeBlock 渲染 pre 代码块
+func (c *markdownConverter) codeBlock(n *html.Node) string {
+	code := strings.TrimRight(rawText(n), "\n")
+	if strings.TrimSpace(code) == "" {
+		return ""
+	}
+
+	lang := codeLanguage(n)
+	for child := n.FirstChild; child != nil && lang == ""; child = child.NextSibling {
+		if child.Type == html.ElementNode && child.Data == "code" {
+			lang = codeLanguage(child)
+		}
+	}
+
+	fence := "```"
+	if strings.Contains(code, "```") {
+		fence = "~~~"
+	}
+	return fence + lang + "\n" + code + "\n" + fence
+}
+
+// table 渲染为 GFM 表格，第一行作为表头
+func (c *markdownConverter) table(n *html.Node) string {
+	var rows [][]string
+	var walk func(*html.Node)
+	walk = func(node *html.Node) {
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			if child.Type != html.ElementNode {
+				continue
+			}
+			switch child.Data {
+			case "thead", "tbody", "tfoot":
+				walk(child)
+			case "tr":
+				var row []string
+				for cell := child.FirstChild; cell != nil; cell = cell.NextSibling {
+					if cell.Type == html.ElementNode && (cell.Data == "td" || cell.Data == "th") {
+						text := cleanInline(spaceRegexp.ReplaceAllString(c.inlineChildren(cell), " "))
+						row = append(row, strings.ReplaceAll(text, "|", `\|`))
+					}
+				}
+				if len(row) > 0 {
+					rows = append(rows, row)
+				}
+			}
+		}
+	}
+	walk(n)
+
+	if len(rows) == 0 {
+		return ""
+	}
+
+	columns := 0
+	for _, row := range rows {
+		if len(row) > columns {
+			columns = len(row)
+		}
+	}
+
+	var b strings.Builder
+	for i, row := range rows {
+		for len(row) < columns {
+			row = append(row, "")
+		}
+		b.WriteString("| " + strings.Join(row, " | ") + " |\n")
+		if i == 0 {
+			b.WriteString("|" + strings.Repeat(" --- |", columns) + "\n")
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// inlineChildren 渲染所有子节点的行内内容
+func (c *markdownConverter) inlineChildren(n *html.Node) string {
+	var b strings.Builder
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		b.WriteString(c.inline(child))
+	}
+	return b.String()
+}
+
+// inline 渲染行内节点
+func (c *markdownConverter) inline(n *html.Node) string {
+	switch n.Type {
+	case html.TextNode:
+		return escapeText(spaceRegexp.ReplaceAllString(n.Data, " "))
+	case html.ElementNode:
+	default:
+		return ""
+	}
+
+	if skippedTags[n.Data] {
+		return ""
+	}
+
+	switch n.Data {
+	case "br":
+		return "\n"
+	case "strong", "b":
+		return wrapInline(c.inlineChildren(n), "**")
+	case "em", "i":
+		return wrapInline(c.inlineChildren(n), "*")
+	case "del", "s", "strike":
+		return wrapInline(c.inlineChildren(n), "~~")
+	case "code", "kbd", "samp":
+		code := spaceRegexp.ReplaceAllString(rawText(n), " ")
+		if strings.TrimSpace(code) == "" {
+			return ""
+		}
+		if strings.Contains(code, "`") {
+			return "`` " + code + " ``"
+		}
+		return "`" + code + "`"
+	case "a":
+		text := strings.TrimSpace(c.inlineChildren(n))
+		href, _ := attr(n, "href")
+		href = strings.TrimSpace(href)
+		if text == "" || href == "" || strings.HasPrefix(href, "#") || strings.HasPrefix(strings.ToLower(href), "javascript:") {
+			return text
+		}
+		return "[" + strings.ReplaceAll(text, "\n", " ") + "](" + c.resolve(href) + ")"
+	case "img":
+		return c.image(n)
+	}
+
+	if isBlock(n.Data) {
+		// 行内上下文中出现块级元素时只保留其文本
+		return " " + c.inlineChildren(n) + " "
+	}
+	return c.inlineChildren(n)
+}
+
+// image 渲染图片，保留 alt 文本
+func (c *markdownConverter) image(n *html.Node) string {
+	alt, _ := attr(n, "alt")
+	alt = escapeText(strings.TrimSpace(spaceRegexp.ReplaceAllString(alt, " ")))
+
+	src, _ := attr(n, "src")
+	if src == "" || strings.HasPrefix(src, "data:") {
+		// 懒加载图片通常把真实地址放在 data-src 中
+		if dataSrc, ok := attr(n, "data-src"); ok {
+			src = dataSrc
+		}
+	}
+	src = strings.TrimSpace(src)
+
+	if src == "" || strings.HasPrefix(src, "data:") {
+		return alt
+	}
+	return "![" + alt + "](" + c.resolve(src) + ")"
+}
+
+// resolve 将相对地址补全为绝对地址
+func (c *markdownConverter) resolve(ref string) string {
+	if c.base == nil {
+		return ref
+	}
+	u, err := url.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return c.base.ResolveReference(u).String()
+}
+
+// isBlock 判断标签是否按块级元素处理
+func isBlock(tag string) bool {
+	switch tag {
+	case "h1", "h2", "h3", "h4", "h5", "h6", "p", "ul", "ol", "li", "pre", "blockquote", "table", "hr":
+		return true
+	}
+	return containerTags[tag]
+}
+
+// cleanInline 清理行内内容首尾及每行的多余空白
+// 行内内容中的换行都来自 br，保留为以反斜杠结尾的 Markdown 硬换行
+func cleanInline(s string) string {
+	return strings.Join(trimLines(s), "\\\n")
+}
+
+// trimLines 去掉每行首尾的空白并丢弃空行
+func trimLines(s string) []string {
+	lines := strings.Split(s, "\n")
+	kept := lines[:0]
+	for _, line := range lines {
+		if line = strings.TrimSpace(line); line != "" {
+			kept = append(kept, line)
+		}
+	}
+	return kept
+}
+
+// escapeText 转义文本中的 Markdown 标记
+// 文本可能位于行首，开头的标题、列表和引用标记同样转义
+func escapeText(s string) string {
+	s = markdownEscaper.Replace(s)
+	trimmed := strings.TrimLeft(s, " ")
+	lead := s[:len(s)-len(trimmed)]
+	switch {
+	case blockMarkerRegexp.MatchString(trimmed):
+		return lead + `\` + trimmed
+	case orderedMarkerRegexp.MatchString(trimmed):
+		return lead + orderedMarkerRegexp.ReplaceAllString(trimmed, `$1\$2$3`)
+	}
+	return s
+}
+
+// wrapInline 用标记包裹内容，首尾空白保留在标记外
+func wrapInline(s, mark string) string {
+	trimmed := strings.TrimSpace(s)
+	if trimmed == "" {
+		return s
+	}
+	lead := s[:strings.Index(s, trimmed)]
+	trail := s[len(lead)+len(trimmed):]
+	return lead + mark + trimmed + mark + trail
+}
+
+// prefixLines 给第一行和后续行分别加上前缀
+func prefixLines(s, first, rest string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		prefix := rest
+		if i == 0 {
+			prefix = first
+		}
+		if line == "" {
+			lines[i] = strings.TrimRight(prefix, " ")
+			continue
+		}
+		lines[i] = prefix + line
+	}
+	return strings.Join(lines, "\n")
+}
+
+// rawText 获取节点内的原始文本，br 转为换行
+func rawText(n *html.Node) string {
+	var b strings.Builder
+	var walk func(*html.Node)
+	walk = func(node *html.Node) {
+		switch {
+		case node.Type == html.TextNode:
+			b.WriteString(node.Data)
+		case node.Type == html.ElementNode && node.Data == "br":
+			b.WriteString("\n")
+		}
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(n)
+	return b.String()
+}
+
+// codeLanguage 从 class 中解析代码语言，例如 language-go
+func codeLanguage(n *html.Node) string {
+	class, _ := attr(n, "class")
+	for _, name := range strings.Fields(class) {
+		for _, prefix := range []string{"language-", "lang-"} {
+			if strings.HasPrefix(name, prefix) {
+				return strings.TrimPrefix(name, prefix)
+			}
+		}
+	}
+	return ""
+}
+
+// attr 获取节点属性
+func attr(n *html.Node, key string) (string, bool) {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
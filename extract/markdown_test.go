@@ -0,0 +1,118 @@
+package extract
+
+import "testing"
+
+func TestHTMLToMarkdown(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{
+			name: "heading and paragraph",
+			html: `<h2>Title</h2><p>Hello <strong>world</strong></p>`,
+			want: "## Title\n\nHello **world**",
+		},
+		{
+			name: "full document",
+			html: `<!DOCTYPE html><html><head><title>t</title></head><body><h1>Doc</h1><p>text</p></body></html>`,
+			want: "# Doc\n\ntext",
+		},
+		{
+			name: "table row fragment",
+			html: `<tr><td>a</td><td>b</td></tr>`,
+			want: "| a | b |\n| --- | --- |",
+		},
+		{
+			name: "table cell fragment",
+			html: `<td>cell</td>`,
+			want: "| cell |\n| --- |",
+		},
+		{
+			name: "list item fragment",
+			html: `<li>one</li><li>two</li>`,
+			want: "- one\n- two",
+		},
+		{
+			name: "ordered list",
+			html: `<ol start="3"><li>a</li><li>b</li></ol>`,
+			want: "3. a\n4. b",
+		},
+		{
+			name: "nested list stays tight",
+			html: `<ul><li>a<ul><li>b</li><li>c</li></ul></li><li>d</li></ul>`,
+			want: "- a\n  - b\n  - c\n- d",
+		},
+		{
+			name: "nested ordered list after paragraph",
+			html: `<ol><li><p>a</p><ol><li>b</li></ol></li></ol>`,
+			want: "1. a\n   1. b",
+		},
+		{
+			name: "escape leading ordered marker",
+			html: `<p>1. not a list</p><p>2024) neither</p>`,
+			want: "1\\. not a list\n\n2024\\) neither",
+		},
+		{
+			name: "escape leading bullet and quote markers",
+			html: `<p>- dash</p><p>+ plus</p><p>&gt; quote</p><p>-1 stays</p>`,
+			want: "\\- dash\n\n\\+ plus\n\n\\> quote\n\n-1 stays",
+		},
+		{
+			name: "br is a hard break",
+			html: `<p>line one<br>line two<br></p>`,
+			want: "line one\\\nline two",
+		},
+		{
+			name: "br inside heading",
+			html: `<h3>a<br>b</h3>`,
+			want: "### a b",
+		},
+		{
+			name: "escape special characters",
+			html: `<p>2 * 3 = 6, snake_case, [note] and a\b</p>`,
+			want: `2 \* 3 = 6, snake\_case, \[note\] and a\\b`,
+		},
+		{
+			name: "escape leading hash",
+			html: `<p># not a heading, C# is fine</p>`,
+			want: `\# not a heading, C# is fine`,
+		},
+		{
+			name: "code is not escaped",
+			html: `<p><code>a*b_c</code></p>`,
+			want: "`a*b_c`",
+		},
+		{
+			name: "code block",
+			html: `<pre><code class="language-go">x := 1 * 2</code></pre>`,
+			want: "```go\nx := 1 * 2\n```",
+		},
+		{
+			name: "relative link and image",
+			html: `<p><a href="/docs">my_docs</a> <img src="img.png" alt="a*b"></p>`,
+			want: "[my\\_docs](https://example.com/docs) ![a\\*b](https://example.com/base/img.png)",
+		},
+		{
+			name: "skipped tags",
+			html: `<div><script>alert(1)</script><p>kept</p></div>`,
+			want: "kept",
+		},
+		{
+			name: "blockquote",
+			html: `<blockquote><p>quoted</p></blockquote>`,
+			want: "> quoted",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := HTMLToMarkdown(tt.html, "https://example.com/base/")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("HTMLToMarkdown() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
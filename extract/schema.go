@@ -0,0 +1,212 @@
+package extract
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/go-rod/rod"
+)
+
+// 选择器末尾的 @属性名，例如 .price@data-price
+var attrSuffixRegexp = regexp.MustCompile(`^(.*)@([A-Za-z_:][-A-Za-z0-9_:.]*)$`)
+
+// Field 结构化提取的字段定义
+type Field struct {
+	// Selector 相对于父元素的 CSS 选择器，为空时表示父元素本身
+	Selector string `json:"selector"`
+	// Attr 读取的属性名，为空时读取内容
+	Attr string `json:"attr,omitempty"`
+	// Type 内容类型：text、html 或 markdown，默认 text
+	Type string `json:"type,omitempty"`
+	// List 为 true 时返回所有匹配元素组成的数组
+	List bool `json:"list,omitempty"`
+	// Fields 嵌套字段，设置后每个匹配元素返回一个对象
+	Fields Schema `json:"fields,omitempty"`
+}
+
+// Schema 字段名到字段定义的映射
+type Schema map[string]*Field
+
+// ParseSchema 解析 JSON 格式的提取规则
+//
+// 字段值支持以下写法：
+//   - "h1"：第一个匹配元素的文本
+//   - ".price@data-price"：第一个匹配元素的属性
+//   - [".tag"]：所有匹配元素的文本数组
+//   - {"selector": ".row", "fields": {...}}：所有匹配元素组成的对象数组，"list": false 时只取第一个
+//   - {"selector": ".content", "type": "html"}：指定内容类型
+func ParseSchema(raw json.RawMessage) (Schema, error) {
+	if len(raw) == 0 {
+		return nil, fmt.Errorf("schema 不能为空")
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, fmt.Errorf("schema 必须是 JSON 对象: %v", err)
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("schema 至少需要一个字段")
+	}
+
+	schema := make(Schema, len(fields))
+	for name, value := range fields {
+		field, err := parseField(value)
+		if err != nil {
+			return nil, fmt.Errorf("字段 '%s' 无效: %v", name, err)
+		}
+		schema[name] = field
+	}
+	return schema, nil
+}
+
+// parseField 解析单个字段定义
+func parseField(raw json.RawMessage) (*Field, error) {
+	var selector string
+	if err := json.Unmarshal(raw, &selector); err == nil {
+		return newSelectorField(selector), nil
+	}
+
+	var list []string
+	if err := json.Unmarshal(raw, &list); err == nil {
+		if len(list) != 1 {
+			return nil, fmt.Errorf("数组写法只能包含一个选择器")
+		}
+		field := newSelectorField(list[0])
+		field.List = true
+		return field, nil
+	}
+
+	var obj struct {
+		Selector string          `json:"selector"`
+		Attr     string          `json:"attr"`
+		Type     string          `json:"type"`
+		List     *bool           `json:"list"`
+		Fields   json.RawMessage `json:"fields"`
+	}
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		return nil, fmt.Errorf("必须是选择器字符串、数组或对象")
+	}
+
+	field := newSelectorField(obj.Selector)
+	if obj.Attr != "" {
+		field.Attr = obj.Attr
+	}
+	if obj.Type != "" {
+		field.Type = obj.Type
+	}
+	switch field.Type {
+	case "text", "html", "markdown":
+	default:
+		return nil, fmt.Errorf("不支持的类型 '%s'，可选 text、html、markdown", field.Type)
+	}
+
+	if len(obj.Fields) > 0 {
+		nested, err := ParseSchema(obj.Fields)
+		if err != nil {
+			return nil, err
+		}
+		field.Fields = nested
+		// 带嵌套字段时默认返回数组
+		field.List = true
+	}
+	if obj.List != nil {
+		field.List = *obj.List
+	}
+
+	return field, nil
+}
+
+// newSelectorField 解析 "选择器@属性" 写法
+func newSelectorField(selector string) *Field {
+	field := &Field{Selector: strings.TrimSpace(selector), Type: "text"}
+	if m := attrSuffixRegexp.FindStringSubmatch(field.Selector); m != nil {
+		field.Selector = strings.TrimSpace(m[1])
+		field.Attr = m[2]
+	}
+	return field
+}
+
+// Evaluate 在 root 元素下按规则提取数据
+// 未匹配到的单值字段返回 nil，数组字段返回空数组
+func (s Schema) Evaluate(root *rod.Element, baseURL string) (map[string]interface{}, error) {
+	result := make(map[string]interface{}, len(s))
+	for name, field := range s {
+		value, err := field.evaluate(root, baseURL)
+		if err != nil {
+			return nil, fmt.Errorf("提取字段 '%s' 失败: %v", name, err)
+		}
+		result[name] = value
+	}
+	return result, nil
+}
+
+// evaluate 提取单个字段
+func (f *Field) evaluate(root *rod.Element, baseURL string) (interface{}, error) {
+	elements := rod.Elements{root}
+	if f.Selector != "" {
+		// Elements 不会等待元素出现，缺失的字段直接返回空值
+		var err error
+		elements, err = root.Elements(f.Selector)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if !f.List {
+		if len(elements) == 0 {
+			return nil, nil
+		}
+		return f.value(elements[0], baseURL)
+	}
+
+	values := make([]interface{}, 0, len(elements))
+	for _, el := range elements {
+		value, err := f.value(el, baseURL)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// value 读取单个元素的值
+func (f *Field) value(el *rod.Element, baseURL string) (interface{}, error) {
+	if f.Fields != nil {
+		return f.Fields.Evaluate(el, baseURL)
+	}
+
+	if f.Attr != "" {
+		value, err := el.Attribute(f.Attr)
+		if err != nil {
+			return nil, err
+		}
+		if value == nil {
+			return nil, nil
+		}
+		// 链接和图片地址补全为绝对地址
+		if f.Attr == "href" || f.Attr == "src" {
+			return newMarkdownConverter(baseURL).resolve(*value), nil
+		}
+		return *value, nil
+	}
+
+	switch f.Type {
+	case "html":
+		return el.HTML()
+	case "markdown":
+		htmlContent, err := el.HTML()
+		if err != nil {
+			return nil, err
+		}
+		return HTMLToMarkdown(htmlContent, baseURL)
+	default:
+		text, err := el.Text()
+		if err != nil {
+			return nil, err
+		}
+		return strings.TrimSpace(text), nil
+	}
+}
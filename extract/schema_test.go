@@ -0,0 +1,152 @@
+package extract
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/launcher"
+)
+
+func TestParseSchema(t *testing.T) {
+	tests := []struct {
+		name    string
+		schema  string
+		want    Schema
+		wantErr bool
+	}{
+		{
+			name:   "selector string reads text",
+			schema: `{"title": "h1"}`,
+			want:   Schema{"title": {Selector: "h1", Type: "text"}},
+		},
+		{
+			name:   "attribute suffix",
+			schema: `{"price": ".price@data-price", "link": "a @href"}`,
+			want: Schema{
+				"price": {Selector: ".price", Attr: "data-price", Type: "text"},
+				"link":  {Selector: "a", Attr: "href", Type: "text"},
+			},
+		},
+		{
+			name:   "array reads all matches",
+			schema: `{"tags": [".tag"]}`,
+			want:   Schema{"tags": {Selector: ".tag", Type: "text", List: true}},
+		},
+		{
+			name:   "object with html type",
+			schema: `{"body": {"selector": ".content", "type": "html"}}`,
+			want:   Schema{"body": {Selector: ".content", Type: "html"}},
+		},
+		{
+			name:   "object with attr",
+			schema: `{"image": {"selector": "img", "attr": "src"}}`,
+			want:   Schema{"image": {Selector: "img", Attr: "src", Type: "text"}},
+		},
+		{
+			name:   "missing selector means the parent element",
+			schema: `{"self": {"type": "markdown"}}`,
+			want:   Schema{"self": {Selector: "", Type: "markdown"}},
+		},
+		{
+			name:   "nested fields default to a list",
+			schema: `{"rows": {"selector": ".row", "fields": {"name": ".name"}}}`,
+			want: Schema{"rows": {Selector: ".row", Type: "text", List: true, Fields: Schema{
+				"name": {Selector: ".name", Type: "text"},
+			}}},
+		},
+		{
+			name:   "nested fields with list false",
+			schema: `{"first": {"selector": ".row", "list": false, "fields": {"name": ".name"}}}`,
+			want: Schema{"first": {Selector: ".row", Type: "text", Fields: Schema{
+				"name": {Selector: ".name", Type: "text"},
+			}}},
+		},
+		{name: "empty", schema: ``, wantErr: true},
+		{name: "not an object", schema: `["h1"]`, wantErr: true},
+		{name: "no fields", schema: `{}`, wantErr: true},
+		{name: "unsupported type", schema: `{"x": {"selector": "p", "type": "pdf"}}`, wantErr: true},
+		{name: "array with two selectors", schema: `{"x": ["a", "b"]}`, wantErr: true},
+		{name: "invalid field value", schema: `{"x": 1}`, wantErr: true},
+		{name: "invalid nested field", schema: `{"x": {"selector": "p", "fields": {"y": true}}}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseSchema(json.RawMessage(tt.schema))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSchema() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseSchema() = %s, want %s", dumpSchema(got), dumpSchema(tt.want))
+			}
+		})
+	}
+}
+
+func TestSchemaEvaluate(t *testing.T) {
+	bin, found := launcher.LookPath()
+	if !found {
+		t.Skip("no browser found, skipping page-based extraction test")
+	}
+	controlURL, err := launcher.New().Bin(bin).Headless(true).Launch()
+	if err != nil {
+		t.Skipf("cannot launch browser: %v", err)
+	}
+	browser := rod.New().ControlURL(controlURL)
+	if err := browser.Connect(); err != nil {
+		t.Skipf("cannot connect to browser: %v", err)
+	}
+	defer browser.Close()
+
+	page := browser.MustPage("")
+	page.MustSetDocumentContent(`<html><body>
+		<h1> Title </h1>
+		<div class="content"><p>Hello <b>world</b></p></div>
+		<a class="more" href="/more">More</a>
+		<span class="tag">a</span><span class="tag">b</span>
+		<div class="row"><span class="name">one</span></div>
+		<div class="row"><span class="name">two</span></div>
+	</body></html>`)
+	root := page.MustElement("body")
+
+	tests := []struct {
+		name   string
+		schema string
+		want   interface{}
+	}{
+		{"text", `{"v": "h1"}`, "Title"},
+		{"html", `{"v": {"selector": ".content p", "type": "html"}}`, "<p>Hello <b>world</b></p>"},
+		{"markdown", `{"v": {"selector": ".content", "type": "markdown"}}`, "Hello **world**"},
+		{"attr resolves href", `{"v": "a.more@href"}`, "https://example.com/more"},
+		{"attr missing", `{"v": "h1@data-missing"}`, nil},
+		{"missing selector", `{"v": ".missing"}`, nil},
+		{"missing list", `{"v": [".missing"]}`, []interface{}{}},
+		{"list", `{"v": [".tag"]}`, []interface{}{"a", "b"}},
+		{"nested", `{"v": {"selector": ".row", "fields": {"name": ".name"}}}`, []interface{}{
+			map[string]interface{}{"name": "one"},
+			map[string]interface{}{"name": "two"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schema, err := ParseSchema(json.RawMessage(tt.schema))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := schema.Evaluate(root, "https://example.com/page")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got["v"], tt.want) {
+				t.Errorf("Evaluate() = %#v, want %#v", got["v"], tt.want)
+			}
+		})
+	}
+}
+
+// dumpSchema 把提取规则编码为 JSON，便于比较失败时查看
+func dumpSchema(s Schema) string {
+	data, _ := json.Marshal(s)
+	return string(data)
+}
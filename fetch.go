@@ -0,0 +1,618 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"textsurf/actions"
+	"textsurf/apperr"
+	"textsurf/extract"
+	"textsurf/modules"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/proto"
+)
+
+// 未指定 user_agent 时使用的 User-Agent
+const defaultUserAgent = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
+
+// FetchRequest 内容提取请求参数
+// GET 请求从 query 读取，POST 请求从 JSON 请求体读取
+type FetchRequest struct {
+	URL          string          `json:"url" form:"url"`
+	Type         string          `json:"type" form:"-"`
+	CSSPath      string          `json:"css_path" form:"css_path"`
+	ClickCSSPath string          `json:"click_css_path" form:"click_css_path"`
+	Extract      string          `json:"extract" form:"extract"`
+	Schema       json.RawMessage `json:"schema,omitempty" form:"-"`
+
+	// 提取前依次执行的页面操作
+	Actions []actions.Action `json:"actions,omitempty" form:"-"`
+
+	// 使用已登录模块会话或命名凭证中的 cookies 访问页面
+	SessionID  string `json:"session_id" form:"session_id"`
+	Credential string `json:"credential" form:"credential"`
+
+	// 请求身份：cookies 支持 Cookie 头格式字符串或 JSON 数组，headers 为额外请求头
+	Cookies        json.RawMessage   `json:"cookies,omitempty" form:"-"`
+	Headers        map[string]string `json:"headers,omitempty" form:"-"`
+	UserAgent      string            `json:"user_agent" form:"user_agent"`
+	AcceptLanguage string            `json:"accept_language" form:"accept_language"`
+	Referer        string            `json:"referer" form:"referer"`
+
+	// 等待策略：wait_until 控制导航和点击后的等待方式，wait_for、wait_gone、wait_js 为额外条件
+	WaitUntil string `json:"wait_until" form:"wait_until"`
+	IdleMs    int    `json:"idle_ms" form:"idle_ms"`
+	WaitFor   string `json:"wait_for" form:"wait_for"`
+	WaitGone  string `json:"wait_gone" form:"wait_gone"`
+	WaitJS    string `json:"wait_js" form:"wait_js"`
+	// FailOnCaptcha 为 true 时页面出现人机验证直接返回 captcha_detected，否则只在结果中标记
+	FailOnCaptcha bool `json:"fail_on_captcha" form:"fail_on_captcha"`
+	// Timeout 整个请求的超时秒数，0 表示使用服务默认超时 (--timeout)
+	Timeout float64 `json:"timeout" form:"timeout"`
+
+	// 截图参数
+	Format   string `json:"format" form:"format"`
+	Quality  int    `json:"quality" form:"quality"`
+	FullPage bool   `json:"full_page" form:"full_page"`
+
+	// PDF 参数，边距支持 in、cm、mm、px 单位，纯数字按英寸处理
+	Paper           string `json:"paper" form:"paper"`
+	Landscape       bool   `json:"landscape" form:"landscape"`
+	PrintBackground bool   `json:"print_background" form:"print_background"`
+	Margin          string `json:"margin" form:"margin"`
+	MarginTop       string `json:"margin_top" form:"margin_top"`
+	MarginRight     string `json:"margin_right" form:"margin_right"`
+	MarginBottom    string `json:"margin_bottom" form:"margin_bottom"`
+	MarginLeft      string `json:"margin_left" form:"margin_left"`
+
+	// 解析后的提取规则
+	schema extract.Schema
+	// 需要注入页面的 cookies
+	cookies []*proto.NetworkCookieParam
+	// 请求中显式传入的 cookies，校验时解析
+	explicitCookies []*proto.NetworkCookieParam
+}
+
+// FetchResult 内容提取结果
+type FetchResult struct {
+	URL          string           `json:"url"`
+	Type         string           `json:"type"`
+	Content      interface{}      `json:"content"`
+	CSSPath      string           `json:"css_path"`
+	ClickCSSPath string           `json:"click_css_path"`
+	Extract      string           `json:"extract,omitempty"`
+	Article      *extract.Article `json:"article,omitempty"`
+	// CaptchaDetected 页面加载后疑似出现验证码或人机验证，按标题和地址判断时可能误报
+	CaptchaDetected bool `json:"captcha_detected,omitempty"`
+
+	// evaluate 操作的返回值
+	ActionResults []interface{} `json:"action_results,omitempty"`
+
+	// 并发受限时的排队毫秒数，同时通过 X-Queue-Time 响应头返回
+	QueueMs int64 `json:"queue_ms"`
+
+	// 截图和 PDF 的二进制内容
+	Data        []byte `json:"-"`
+	ContentType string `json:"content_type,omitempty"`
+}
+
+// inlineData 把截图和 PDF 的二进制内容以 base64 编码放入 content，用于 JSON 响应
+func (r *FetchResult) inlineData() {
+	if r.Data != nil {
+		r.Content = base64.StdEncoding.EncodeToString(r.Data)
+	}
+}
+
+// bindFetchRequest 从请求中读取提取参数并校验
+func bindFetchRequest(c *gin.Context) (*FetchRequest, error) {
+	req := &FetchRequest{}
+
+	if c.Request.Method == http.MethodPost {
+		if err := c.ShouldBindJSON(req); err != nil {
+			return nil, fmt.Errorf("Invalid request body: %v", err)
+		}
+	} else {
+		if err := c.ShouldBindQuery(req); err != nil {
+			return nil, fmt.Errorf("Invalid query parameters: %v", err)
+		}
+		if schema := c.Query("schema"); schema != "" {
+			req.Schema = json.RawMessage(schema)
+		}
+		if steps := c.Query("actions"); steps != "" {
+			parsed, err := actions.Parse(steps)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid actions: %v", err)
+			}
+			req.Actions = parsed
+		}
+		if cookies := c.Query("cookies"); cookies != "" {
+			// 非 JSON 数组时按 Cookie 头格式处理
+			if strings.HasPrefix(strings.TrimSpace(cookies), "[") {
+				req.Cookies = json.RawMessage(cookies)
+			} else {
+				quoted, _ := json.Marshal(cookies)
+				req.Cookies = quoted
+			}
+		}
+		// header 参数格式为 "Name: value"，可重复
+		for _, line := range c.QueryArray("header") {
+			name, value, found := strings.Cut(line, ":")
+			if !found || strings.TrimSpace(name) == "" {
+				return nil, fmt.Errorf("Invalid header '%s'. Use 'Name: value'", line)
+			}
+			if req.Headers == nil {
+				req.Headers = make(map[string]string)
+			}
+			req.Headers[strings.TrimSpace(name)] = strings.TrimSpace(value)
+		}
+	}
+
+	// 返回类型由路径参数指定
+	req.Type = c.Param("type")
+
+	if err := req.validate(); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// validate 校验参数并解析提取规则
+func (req *FetchRequest) validate() error {
+	switch req.Type {
+	case "text", "html", "markdown", "json", "screenshot", "pdf":
+	default:
+		return fmt.Errorf("Invalid return type. Use 'text', 'html', 'markdown', 'json', 'screenshot' or 'pdf'")
+	}
+
+	if req.URL == "" {
+		return fmt.Errorf("Missing required parameter: url")
+	}
+
+	if req.Extract != "" && req.Extract != "article" {
+		return fmt.Errorf("Invalid extract mode. Use 'article'")
+	}
+
+	if err := actions.Validate(req.Actions); err != nil {
+		return fmt.Errorf("Invalid actions: %v", err)
+	}
+
+	if err := req.validateWait(); err != nil {
+		return err
+	}
+
+	// 在启动批量和异步任务的工作协程前解析 cookies
+	if len(req.Cookies) > 0 {
+		cookies, err := parseCookies(req.Cookies, req.URL)
+		if err != nil {
+			return fmt.Errorf("Invalid cookies: %v", err)
+		}
+		req.explicitCookies = cookies
+	}
+
+	if req.Type == "json" {
+		if req.Extract != "" {
+			return fmt.Errorf("Parameter 'extract' is not supported for type 'json'")
+		}
+		schema, err := extract.ParseSchema(req.Schema)
+		if err != nil {
+			return fmt.Errorf("Invalid schema: %v", err)
+		}
+		req.schema = schema
+	}
+
+	if req.Type == "screenshot" || req.Type == "pdf" {
+		if req.Extract != "" {
+			return fmt.Errorf("Parameter 'extract' is not supported for type '%s'", req.Type)
+		}
+		if err := req.validateCapture(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// loadCookies 读取登录会话或命名凭证中的 cookies
+// 检查会话登录状态时绑定请求上下文
+func (req *FetchRequest) loadCookies(ctx context.Context) error {
+	if req.SessionID != "" && req.Credential != "" {
+		return fmt.Errorf("Parameters 'session_id' and 'credential' cannot be used together")
+	}
+
+	if req.SessionID != "" {
+		session, exists := sessionManager.GetSession(req.SessionID)
+		if !exists {
+			return apperr.New(apperr.NotFound, "Session '%s' not found", req.SessionID)
+		}
+
+		loggedIn, _, err := session.Module.CheckLogin(bindSession(ctx, session))
+		if err != nil {
+			return contextError(ctx, requestTimeout(req.Timeout), apperr.Wrap(err, apperr.Internal, "Failed to check login status"))
+		}
+		if !loggedIn {
+			return fmt.Errorf("Session '%s' is not logged in", req.SessionID)
+		}
+
+		cookies, err := session.Browser.GetCookies()
+		if err != nil {
+			return apperr.Wrap(err, apperr.Internal, "Failed to read session cookies")
+		}
+		req.cookies = proto.CookiesToParams(cookies)
+	}
+
+	if req.Credential != "" {
+		credential, exists := credentialStore.Get(req.Credential)
+		if !exists {
+			return apperr.New(apperr.NotFound, "Credential '%s' not found", req.Credential)
+		}
+		req.cookies = proto.CookiesToParams(credential.Cookies)
+	}
+
+	// 显式传入的 cookies 追加在登录态之后，同名时覆盖
+	req.cookies = append(req.cookies, req.explicitCookies...)
+
+	return nil
+}
+
+// parseCookies 解析 Cookie 头格式字符串或 JSON 数组
+// 未指定 domain 和 url 的 cookie 绑定到目标地址
+func parseCookies(raw json.RawMessage, targetURL string) ([]*proto.NetworkCookieParam, error) {
+	var cookies []*proto.NetworkCookieParam
+
+	var header string
+	if err := json.Unmarshal(raw, &header); err == nil {
+		for _, pair := range strings.Split(header, ";") {
+			pair = strings.TrimSpace(pair)
+			if pair == "" {
+				continue
+			}
+			name, value, found := strings.Cut(pair, "=")
+			if !found || strings.TrimSpace(name) == "" {
+				return nil, fmt.Errorf("invalid cookie pair '%s'", pair)
+			}
+			cookies = append(cookies, &proto.NetworkCookieParam{
+				Name:  strings.TrimSpace(name),
+				Value: strings.TrimSpace(value),
+			})
+		}
+	} else if err := json.Unmarshal(raw, &cookies); err != nil {
+		return nil, fmt.Errorf("must be a Cookie header string or an array of {name, value, domain, path} objects")
+	}
+
+	for _, cookie := range cookies {
+		if cookie == nil {
+			return nil, fmt.Errorf("cookie must be an object")
+		}
+		if cookie.Name == "" {
+			return nil, fmt.Errorf("cookie name is required")
+		}
+		if cookie.Domain == "" && cookie.URL == "" {
+			cookie.URL = targetURL
+		}
+	}
+	return cookies, nil
+}
+
+// runFetch 打开页面并按请求提取内容
+// 页面操作绑定 ctx，超时或取消时返回对应的请求错误
+func runFetch(ctx context.Context, req *FetchRequest) (*FetchResult, error) {
+	slot, release, queued, err := acquireFetch(ctx, req.URL)
+	if err != nil {
+		return nil, contextError(ctx, requestTimeout(req.Timeout), err)
+	}
+	defer release()
+	defer func() { slot.release() }()
+
+	// 从页面池获取页面，池满时排队等待
+	tab, err := pagePool.Get(ctx)
+	if err != nil {
+		return nil, contextError(ctx, requestTimeout(req.Timeout), apperr.Wrap(err, apperr.BrowserCrashed, "Failed to get page from pool"))
+	}
+	// 归还使用未绑定的页面，请求取消后仍能正常重置
+	defer pagePool.Put(tab)
+
+	// 每个页面使用独立的无痕上下文，cookies 不会泄露到其他请求
+	if len(req.cookies) > 0 {
+		if err := tab.Context.SetCookies(req.cookies); err != nil {
+			return nil, apperr.Wrap(err, apperr.Internal, "Failed to set cookies")
+		}
+	}
+
+	if queued > 0 {
+		fmt.Printf("Fetch of %s waited %v in queue\n", req.URL, queued)
+	}
+
+	result, err := fetchPage(tab.Page.Context(ctx), req, slot.navigate)
+	if err != nil {
+		return nil, contextError(ctx, requestTimeout(req.Timeout), err)
+	}
+	result.QueueMs = queued.Milliseconds()
+	return result, nil
+}
+
+// acquireFetch 依次获取目标站点的名额和全局并发名额，返回站点名额、全局名额的释放函数和排队时间
+// 先等待站点限制，等待受限站点的请求不占用全局名额，不会拖慢其他站点的请求
+func acquireFetch(ctx context.Context, targetURL string) (*hostSlot, func(), time.Duration, error) {
+	// 按站点限制并发和访问频率，等待时间计入排队时间
+	host := requestHost(targetURL)
+	hostRelease, queued, err := hostLimiter.Acquire(ctx, host)
+	if err != nil {
+		return nil, nil, queued, apperr.Wrap(err, apperr.Internal, "Failed to wait for host rate limit")
+	}
+
+	// 超过并发上限时排队等待，队列已满时返回 queue_full
+	release, globalQueued, err := fetchLimiter.Acquire(ctx)
+	if err != nil {
+		hostRelease()
+		return nil, nil, queued + globalQueued, apperr.Wrap(err, apperr.QueueFull, "Too many concurrent fetch requests")
+	}
+	return &hostSlot{host: host, release: hostRelease}, release, queued + globalQueued, nil
+}
+
+// requestHost 返回目标地址的主机名，解析失败时返回空字符串
+func requestHost(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
+// hostSlot 页面当前占用的站点名额，navigate 操作切换到其他站点时同时切换名额
+type hostSlot struct {
+	host    string
+	release func()
+}
+
+// navigate 在 navigate 操作导航前等待目标站点的访问限制
+// 同一站点只等待导航间隔，其他站点先释放当前名额再获取新站点的名额，避免两个请求互相等待
+func (s *hostSlot) navigate(ctx context.Context, targetURL string) error {
+	host := requestHost(targetURL)
+	if strings.EqualFold(host, s.host) {
+		_, err := hostLimiter.Wait(ctx, host)
+		return err
+	}
+
+	s.release()
+	s.host, s.release = host, func() {}
+	release, _, err := hostLimiter.Acquire(ctx, host)
+	if err != nil {
+		return err
+	}
+	s.release = release
+	return nil
+}
+
+// fetchPage 在已创建的页面上完成导航、等待、操作和内容提取
+// beforeNavigate 在 navigate 操作导航前调用
+func fetchPage(page *rod.Page, req *FetchRequest, beforeNavigate actions.NavigateHook) (*FetchResult, error) {
+	// 设置 User-Agent 和 Accept-Language
+	userAgent := req.UserAgent
+	if userAgent == "" {
+		userAgent = defaultUserAgent
+	}
+	err := page.SetUserAgent(&proto.NetworkSetUserAgentOverride{
+		UserAgent:      userAgent,
+		AcceptLanguage: req.AcceptLanguage,
+	})
+	if err != nil {
+		return nil, apperr.Wrap(err, apperr.Internal, "Failed to set user agent")
+	}
+
+	// 设置额外请求头和 Referer
+	var headers []string
+	for name, value := range req.Headers {
+		headers = append(headers, name, value)
+	}
+	if req.Referer != "" {
+		headers = append(headers, "Referer", req.Referer)
+	}
+	if len(headers) > 0 {
+		if _, err := page.SetExtraHeaders(headers); err != nil {
+			return nil, apperr.Wrap(err, apperr.Internal, "Failed to set extra headers")
+		}
+	}
+
+	// 导航到目标页面并等待页面加载完成
+	wait := prepareWait(page, req, 1*time.Second)
+	if err := page.Navigate(req.URL); err != nil {
+		return nil, apperr.Wrap(err, apperr.NavigationFailed, "Failed to navigate to '%s'", req.URL)
+	}
+	if err := wait(); err != nil {
+		return nil, apperr.Wrap(err, apperr.Internal, "Error waiting for page load")
+	}
+
+	// 疑似人机验证时默认只在结果中标记，请求 fail_on_captcha 时直接返回错误
+	captcha, _ := modules.DetectCaptcha(page)
+	if captcha && req.FailOnCaptcha {
+		return nil, apperr.New(apperr.CaptchaDetected, "Captcha or bot challenge detected on '%s'", pageURL(page))
+	}
+
+	// 如果提供了点击路径，先执行点击操作
+	if req.ClickCSSPath != "" {
+		fmt.Printf("Attempting to click element with CSS path: %s\n", req.ClickCSSPath)
+		clickElement, err := findElement(page, req.ClickCSSPath)
+		if err != nil {
+			return nil, err
+		}
+
+		// 等待点击后的内容加载
+		wait := prepareWait(page, req, 2*time.Second)
+		err = clickElement.Click(proto.InputMouseButtonLeft, 1)
+		if err != nil {
+			return nil, apperr.Wrap(err, apperr.Internal, "Error clicking element")
+		}
+		if err := wait(); err != nil {
+			return nil, apperr.Wrap(err, apperr.Internal, "Error waiting after click")
+		}
+		fmt.Println("Successfully clicked element")
+	}
+
+	// 等待额外条件
+	if err := waitConditions(page, req); err != nil {
+		return nil, err
+	}
+
+	result := &FetchResult{
+		URL:             req.URL,
+		Type:            req.Type,
+		CSSPath:         req.CSSPath,
+		ClickCSSPath:    req.ClickCSSPath,
+		CaptchaDetected: captcha,
+	}
+
+	// 依次执行页面操作
+	if len(req.Actions) > 0 {
+		fmt.Printf("Running %d actions\n", len(req.Actions))
+		results, err := actions.Run(page, req.Actions, beforeNavigate)
+		if err != nil {
+			return nil, apperr.Wrap(err, apperr.Internal, "Error running actions")
+		}
+		result.ActionResults = results
+	}
+
+	// 截图和 PDF 直接返回二进制内容
+	switch req.Type {
+	case "screenshot":
+		fmt.Println("Capturing screenshot")
+		return captureScreenshot(page, req, result)
+	case "pdf":
+		fmt.Println("Printing page to PDF")
+		return printPDF(page, req, result)
+	}
+
+	// 正文提取模式
+	if req.Extract == "article" {
+		fmt.Println("Extracting main article content")
+		content, article, err := articleContent(page, req.CSSPath, req.Type)
+		if err != nil {
+			return nil, apperr.Wrap(err, apperr.Internal, "Error extracting article")
+		}
+		result.Content = content
+		result.Extract = req.Extract
+		result.Article = article
+		return result, nil
+	}
+
+	// 根据是否提供 CSS 路径来确定提取范围
+	var root *rod.Element
+	if req.CSSPath != "" {
+		// 获取指定 CSS 路径的内容
+		fmt.Printf("Getting content from CSS path: %s\n", req.CSSPath)
+		root, err = findElement(page, req.CSSPath)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		// 获取整个页面的内容
+		fmt.Println("Getting full page content")
+		root, err = findElement(page, "body")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if req.Type == "json" {
+		data, err := req.schema.Evaluate(root, pageURL(page))
+		if err != nil {
+			return nil, apperr.Wrap(err, apperr.Internal, "Error extracting structured data")
+		}
+		result.Content = data
+		return result, nil
+	}
+
+	content, err := elementContent(page, root, req.Type)
+	if err != nil {
+		return nil, apperr.Wrap(err, apperr.Internal, "Error getting %s content", req.Type)
+	}
+	result.Content = content
+
+	return result, nil
+}
+
+// findElement 等待选择器对应的元素出现，超时仍未出现时返回 selector_not_found
+func findElement(page *rod.Page, selector string) (*rod.Element, error) {
+	element, err := page.Element(selector)
+	if err == nil {
+		return element, nil
+	}
+	if errors.Is(page.GetContext().Err(), context.DeadlineExceeded) {
+		return nil, &apperr.Error{
+			Code:    apperr.SelectorNotFound,
+			Message: fmt.Sprintf("Element with CSS path '%s' not found before timeout", selector),
+			Err:     err,
+		}
+	}
+	return nil, apperr.Wrap(err, apperr.Internal, "Error finding element with CSS path '%s'", selector)
+}
+
+// elementContent 按返回类型获取元素内容
+func elementContent(page *rod.Page, element *rod.Element, returnType string) (string, error) {
+	switch returnType {
+	case "text":
+		return element.Text()
+	case "markdown":
+		htmlContent, err := element.HTML()
+		if err != nil {
+			return "", err
+		}
+
+		// 使用页面最终地址补全相对链接
+		return extract.HTMLToMarkdown(htmlContent, pageURL(page))
+	default:
+		return element.HTML()
+	}
+}
+
+// articleContent 提取正文并按返回类型输出
+// 未指定 CSS 路径时使用整页 HTML，以便读取 head 中的元信息
+func articleContent(page *rod.Page, cssPath string, returnType string) (string, *extract.Article, error) {
+	var source string
+	if cssPath != "" {
+		element, err := findElement(page, cssPath)
+		if err != nil {
+			return "", nil, err
+		}
+		if source, err = element.HTML(); err != nil {
+			return "", nil, err
+		}
+	} else {
+		var err error
+		if source, err = page.HTML(); err != nil {
+			return "", nil, err
+		}
+	}
+
+	baseURL := pageURL(page)
+	article, err := extract.ExtractArticle(source, baseURL)
+	if err != nil {
+		return "", nil, err
+	}
+
+	switch returnType {
+	case "text":
+		return article.Text, article, nil
+	case "markdown":
+		content, err := extract.HTMLToMarkdown(article.Content, baseURL)
+		return content, article, err
+	default:
+		return article.Content, article, nil
+	}
+}
+
+// pageURL 获取页面当前地址，失败时返回空字符串
+func pageURL(page *rod.Page) string {
+	if info, err := page.Info(); err == nil {
+		return info.URL
+	}
+	return ""
+}
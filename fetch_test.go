@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"textsurf/limiter"
+)
+
+func TestParseCookies(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		want    []string
+		wantErr bool
+	}{
+		{"header string", `"sid=abc; lang=zh"`, []string{"sid", "lang"}, false},
+		{"json array", `[{"name": "sid", "value": "abc"}]`, []string{"sid"}, false},
+		{"null element", `[null]`, nil, true},
+		{"missing name", `[{"value": "abc"}]`, nil, true},
+		{"invalid pair", `"sid"`, nil, true},
+		{"not a string or array", `{"name": "sid"}`, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cookies, err := parseCookies(json.RawMessage(tt.raw), "https://example.com/")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseCookies() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(cookies) != len(tt.want) {
+				t.Fatalf("parseCookies() returned %d cookies, want %d", len(cookies), len(tt.want))
+			}
+			for i, cookie := range cookies {
+				if cookie.Name != tt.want[i] || cookie.URL != "https://example.com/" {
+					t.Errorf("cookie %d = %s %s, want %s bound to the target URL", i, cookie.Name, cookie.URL, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestValidateRejectsNullCookie(t *testing.T) {
+	req := &FetchRequest{URL: "https://example.com/", Type: "text", Cookies: json.RawMessage(`[null]`)}
+	if err := req.validate(); err == nil {
+		t.Fatal("validate() accepted a null cookie")
+	}
+}
+
+func TestThrottledHostDoesNotBlockOtherHosts(t *testing.T) {
+	one := 1
+	l, err := limiter.New(2, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := limiter.NewHostLimiter(limiter.HostRule{}, map[string]limiter.HostOverride{
+		"slow.example.com": {MaxConcurrent: &one},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	previousLimiter, previousHostLimiter := fetchLimiter, hostLimiter
+	fetchLimiter, hostLimiter = l, h
+	defer func() { fetchLimiter, hostLimiter = previousLimiter, previousHostLimiter }()
+
+	// 第一个请求占用受限站点的唯一名额
+	slot, release, _, err := acquireFetch(context.Background(), "https://slow.example.com/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+	defer slot.release()
+
+	// 第二个请求等待受限站点，不应占用全局名额
+	waitCtx, cancelWait := context.WithCancel(context.Background())
+	defer cancelWait()
+	waiting := make(chan error, 1)
+	go func() {
+		_, _, _, err := acquireFetch(waitCtx, "https://slow.example.com/b")
+		waiting <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	// 全局还剩一个名额，其他站点的请求立即执行
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	otherSlot, otherRelease, queued, err := acquireFetch(ctx, "https://fast.example.org/")
+	if err != nil {
+		t.Fatalf("request to another host blocked by a throttled host: %v", err)
+	}
+	if queued > 100*time.Millisecond {
+		t.Errorf("request to another host queued %v", queued)
+	}
+	otherRelease()
+	otherSlot.release()
+
+	cancelWait()
+	if err := <-waiting; err == nil {
+		t.Error("request waiting on the throttled host acquired a slot while it was held")
+	}
+	if active := fetchLimiter.Stats().Active; active != 1 {
+		t.Errorf("active global slots = %d, want 1", active)
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"textsurf/apperr"
+	"textsurf/jobs"
+
+	"github.com/gin-gonic/gin"
+)
+
+// handleCreateJob 提交异步提取任务，请求体与 POST /fetch/{type} 相同，返回类型由 type 字段指定
+func handleCreateJob(c *gin.Context) {
+	req := &FetchRequest{}
+	if err := c.ShouldBindJSON(req); err != nil {
+		respondError(c, apperr.New(apperr.InvalidRequest, "Invalid request body: %v", err))
+		return
+	}
+	if err := req.validate(); err != nil {
+		respondError(c, apperr.From(err, apperr.InvalidRequest))
+		return
+	}
+
+	job, err := jobManager.Submit(func(ctx context.Context) (interface{}, error) {
+		// 超时从任务开始执行时计算
+		ctx, cancel := withRequestTimeout(ctx, req.Timeout)
+		defer cancel()
+
+		if err := req.loadCookies(ctx); err != nil {
+			return nil, apperr.From(err, apperr.InvalidRequest)
+		}
+
+		result, err := runFetch(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		result.inlineData()
+		return result, nil
+	})
+	if err != nil {
+		respondError(c, apperr.Wrap(err, apperr.QueueFull, "Too many queued jobs"))
+		return
+	}
+
+	fmt.Printf("Job %s submitted for %s\n", job.ID, req.URL)
+
+	c.Header("Location", "/jobs/"+job.ID)
+	c.JSON(http.StatusAccepted, jobResponse(job, false))
+}
+
+// handleGetJob 查询任务状态，结束的任务同时返回结果或错误
+func handleGetJob(c *gin.Context) {
+	job, exists := jobManager.Get(c.Param("id"))
+	if !exists {
+		respondError(c, apperr.New(apperr.NotFound, "Job '%s' not found", c.Param("id")))
+		return
+	}
+	c.JSON(http.StatusOK, jobResponse(job, true))
+}
+
+// handleListJobs 列出保留中的任务，不包含结果
+func handleListJobs(c *gin.Context) {
+	list := jobManager.List()
+	items := make([]gin.H, 0, len(list))
+	for _, job := range list {
+		items = append(items, jobResponse(job, false))
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"jobs":  items,
+		"stats": jobManager.Stats(),
+	})
+}
+
+// handleCancelJob 取消等待或执行中的任务，已结束的任务直接删除
+func handleCancelJob(c *gin.Context) {
+	job, exists := jobManager.Cancel(c.Param("id"))
+	if !exists {
+		respondError(c, apperr.New(apperr.NotFound, "Job '%s' not found", c.Param("id")))
+		return
+	}
+	c.JSON(http.StatusOK, jobResponse(job, false))
+}
+
+// jobResponse 任务的 JSON 响应，withResult 为 true 时包含结果
+func jobResponse(job jobs.Job, withResult bool) gin.H {
+	response := gin.H{
+		"id":         job.ID,
+		"status":     job.Status,
+		"created_at": job.CreatedAt,
+	}
+	if !job.StartedAt.IsZero() {
+		response["started_at"] = job.StartedAt
+	}
+	if !job.FinishedAt.IsZero() {
+		response["finished_at"] = job.FinishedAt
+		response["expires_at"] = job.ExpiresAt
+		response["duration_ms"] = jobDuration(job).Milliseconds()
+	}
+	if job.Err != nil {
+		response["error"] = errorBody(job.Err)
+	}
+	if withResult && job.Result != nil {
+		response["result"] = job.Result
+	}
+	return response
+}
+
+// jobDuration 任务执行耗时，未开始执行就被取消时为 0
+func jobDuration(job jobs.Job) time.Duration {
+	if job.StartedAt.IsZero() {
+		return 0
+	}
+	return job.FinishedAt.Sub(job.StartedAt)
+}
@@ -0,0 +1,328 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+
+	"textsurf/apperr"
+	"textsurf/limiter"
+
+	"github.com/google/uuid"
+)
+
+// 没有历史数据时估算的单个任务耗时
+const defaultDuration = 5 * time.Second
+
+// Status 任务状态
+type Status string
+
+// 任务状态
+const (
+	// Queued 等待执行
+	Queued Status = "queued"
+	// Running 执行中
+	Running Status = "running"
+	// Succeeded 执行成功
+	Succeeded Status = "succeeded"
+	// Failed 执行失败
+	Failed Status = "failed"
+	// Canceled 已取消
+	Canceled Status = "canceled"
+)
+
+// Finished 判断任务是否已结束
+func (s Status) Finished() bool {
+	return s == Succeeded || s == Failed || s == Canceled
+}
+
+// Func 任务执行函数，ctx 在任务取消或管理器关闭时结束
+type Func func(ctx context.Context) (interface{}, error)
+
+// Job 任务快照
+type Job struct {
+	ID         string
+	Status     Status
+	CreatedAt  time.Time
+	StartedAt  time.Time
+	FinishedAt time.Time
+	// ExpiresAt 结束的任务在该时间后被清理
+	ExpiresAt time.Time
+	Result    interface{}
+	Err       error
+
+	cancel context.CancelFunc
+}
+
+// Stats 任务管理器状态
+type Stats struct {
+	Queued    int `json:"queued"`
+	Running   int `json:"running"`
+	Finished  int `json:"finished"`
+	MaxQueue  int `json:"max_queue"`
+	Retention int `json:"retention_seconds"`
+}
+
+// Manager 异步任务管理器
+// 同时执行的任务数不超过 concurrency，等待中的任务不超过 maxQueue，结束的任务保留 retention 后清理
+type Manager struct {
+	jobs      map[string]*Job
+	mutex     sync.Mutex
+	slots     chan struct{}
+	maxQueue  int
+	retention time.Duration
+
+	// queued、running 等待和执行中的任务数，avg 任务平均耗时，用于估算 Retry-After
+	queued  int
+	running int
+	avg     time.Duration
+
+	ctx       context.Context
+	stop      context.CancelFunc
+	closeOnce sync.Once
+}
+
+// NewManager 创建任务管理器
+// maxQueue 为没有执行名额时可以等待的任务数，0 表示不排队
+func NewManager(concurrency, maxQueue int, retention time.Duration) (*Manager, error) {
+	if concurrency < 1 {
+		return nil, fmt.Errorf("任务并发数必须大于 0")
+	}
+	if maxQueue < 0 {
+		return nil, fmt.Errorf("任务队列长度不能为负数")
+	}
+	if retention <= 0 {
+		return nil, fmt.Errorf("任务保留时间必须大于 0")
+	}
+
+	ctx, stop := context.WithCancel(context.Background())
+	m := &Manager{
+		jobs:      make(map[string]*Job),
+		slots:     make(chan struct{}, concurrency),
+		maxQueue:  maxQueue,
+		retention: retention,
+		avg:       defaultDuration,
+		ctx:       ctx,
+		stop:      stop,
+	}
+
+	go m.cleanupExpiredJobs()
+
+	return m, nil
+}
+
+// Submit 提交任务，任务在后台排队执行，返回提交时的快照
+// 等待中的任务超过队列长度时返回 *limiter.QueueFullError
+func (m *Manager) Submit(fn Func) (Job, error) {
+	m.mutex.Lock()
+	if m.queued+m.running >= cap(m.slots)+m.maxQueue {
+		err := &limiter.QueueFullError{RetryAfter: m.retryAfterLocked()}
+		m.mutex.Unlock()
+		return Job{}, err
+	}
+
+	ctx, cancel := context.WithCancel(m.ctx)
+	job := &Job{
+		ID:        uuid.New().String(),
+		Status:    Queued,
+		CreatedAt: time.Now(),
+		cancel:    cancel,
+	}
+	m.jobs[job.ID] = job
+	m.queued++
+	snapshot := *job
+	m.mutex.Unlock()
+
+	go m.run(ctx, job, fn)
+
+	return snapshot, nil
+}
+
+// Get 获取任务快照
+func (m *Manager) Get(id string) (Job, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	job, exists := m.jobs[id]
+	if !exists {
+		return Job{}, false
+	}
+	return *job, true
+}
+
+// List 按创建时间返回所有任务的快照
+func (m *Manager) List() []Job {
+	m.mutex.Lock()
+	list := make([]Job, 0, len(m.jobs))
+	for _, job := range m.jobs {
+		list = append(list, *job)
+	}
+	m.mutex.Unlock()
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].CreatedAt.Before(list[j].CreatedAt)
+	})
+	return list
+}
+
+// Cancel 取消等待或执行中的任务，已结束的任务直接删除
+// 返回操作后的任务快照
+func (m *Manager) Cancel(id string) (Job, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	job, exists := m.jobs[id]
+	if !exists {
+		return Job{}, false
+	}
+
+	if job.Status.Finished() {
+		delete(m.jobs, id)
+		return *job, true
+	}
+
+	job.cancel()
+	m.finishLocked(job, Canceled, nil, nil)
+	return *job, true
+}
+
+// Stats 返回任务管理器状态
+func (m *Manager) Stats() Stats {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	stats := Stats{MaxQueue: m.maxQueue, Retention: int(m.retention.Seconds())}
+	for _, job := range m.jobs {
+		switch {
+		case job.Status == Queued:
+			stats.Queued++
+		case job.Status == Running:
+			stats.Running++
+		default:
+			stats.Finished++
+		}
+	}
+	return stats
+}
+
+// Close 取消所有未结束的任务并停止清理
+func (m *Manager) Close() {
+	m.closeOnce.Do(m.stop)
+}
+
+// run 等待执行名额后执行任务并记录结果
+func (m *Manager) run(ctx context.Context, job *Job, fn Func) {
+	defer job.cancel()
+
+	select {
+	case m.slots <- struct{}{}:
+	case <-ctx.Done():
+		m.finish(job, Canceled, nil, nil)
+		return
+	}
+	defer func() { <-m.slots }()
+
+	m.mutex.Lock()
+	if job.Status != Queued {
+		// 获取名额前已被取消
+		m.mutex.Unlock()
+		return
+	}
+	job.Status = Running
+	job.StartedAt = time.Now()
+	m.queued--
+	m.running++
+	m.mutex.Unlock()
+
+	result, err := call(ctx, fn)
+
+	switch {
+	case errors.Is(ctx.Err(), context.Canceled):
+		m.finish(job, Canceled, nil, nil)
+	case err != nil:
+		m.finish(job, Failed, nil, err)
+	default:
+		m.finish(job, Succeeded, result, nil)
+	}
+}
+
+// call 执行任务函数，panic 时作为 internal 错误返回
+// 任务在后台协程中执行，未恢复的 panic 会导致整个进程退出
+func call(ctx context.Context, fn Func) (result interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("任务执行 panic: %v\n%s", r, debug.Stack())
+			result, err = nil, apperr.New(apperr.Internal, "Job panicked: %v", r)
+		}
+	}()
+	return fn(ctx)
+}
+
+// finish 记录任务结果
+func (m *Manager) finish(job *Job, status Status, result interface{}, err error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.finishLocked(job, status, result, err)
+}
+
+// finishLocked 记录任务结果，已结束的任务保持不变，调用方需持有锁
+func (m *Manager) finishLocked(job *Job, status Status, result interface{}, err error) {
+	switch job.Status {
+	case Queued:
+		m.queued--
+	case Running:
+		m.running--
+		// 按指数移动平均更新任务平均耗时，只统计执行过的任务
+		m.avg = (m.avg*4 + time.Since(job.StartedAt)) / 5
+	default:
+		return
+	}
+	job.Status = status
+	job.Result = result
+	job.Err = err
+	job.FinishedAt = time.Now()
+	job.ExpiresAt = job.FinishedAt.Add(m.retention)
+}
+
+// retryAfterLocked 按平均耗时估算等待中的任务开始执行所需时间，调用方需持有锁
+func (m *Manager) retryAfterLocked() time.Duration {
+	rounds := float64(m.queued+1) / float64(cap(m.slots))
+	return time.Duration(math.Ceil(rounds) * float64(m.avg))
+}
+
+// cleanupExpiredJobs 定期清理超过保留时间的任务
+func (m *Manager) cleanupExpiredJobs() {
+	interval := m.retention / 2
+	if interval > time.Minute {
+		interval = time.Minute
+	}
+	if interval < time.Second {
+		interval = time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		now := time.Now()
+		m.mutex.Lock()
+		for id, job := range m.jobs {
+			if job.Status.Finished() && now.After(job.ExpiresAt) {
+				delete(m.jobs, id)
+			}
+		}
+		m.mutex.Unlock()
+	}
+}
@@ -0,0 +1,320 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"textsurf/apperr"
+	"textsurf/limiter"
+)
+
+func TestNewManager(t *testing.T) {
+	tests := []struct {
+		name        string
+		concurrency int
+		maxQueue    int
+		retention   time.Duration
+		wantErr     bool
+	}{
+		{"valid", 2, 10, time.Hour, false},
+		{"no queue", 1, 0, time.Hour, false},
+		{"zero concurrency", 0, 10, time.Hour, true},
+		{"negative queue", 1, -1, time.Hour, true},
+		{"zero retention", 1, 10, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := NewManager(tt.concurrency, tt.maxQueue, tt.retention)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewManager error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if m != nil {
+				m.Close()
+			}
+		})
+	}
+}
+
+func TestSubmitResult(t *testing.T) {
+	failure := errors.New("failed")
+	tests := []struct {
+		name   string
+		fn     Func
+		status Status
+		result interface{}
+		err    error
+	}{
+		{"succeeded", func(context.Context) (interface{}, error) { return "ok", nil }, Succeeded, "ok", nil},
+		{"failed", func(context.Context) (interface{}, error) { return nil, failure }, Failed, nil, failure},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestManager(t, 1, 1, time.Hour)
+
+			job, err := m.Submit(tt.fn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if job.Status != Queued {
+				t.Errorf("submitted status = %s, want %s", job.Status, Queued)
+			}
+
+			job = waitStatus(t, m, job.ID, tt.status)
+			if job.Result != tt.result || !errors.Is(job.Err, tt.err) {
+				t.Errorf("job result = %v, %v, want %v, %v", job.Result, job.Err, tt.result, tt.err)
+			}
+			if job.StartedAt.IsZero() || job.FinishedAt.Before(job.StartedAt) {
+				t.Errorf("job times started=%v finished=%v", job.StartedAt, job.FinishedAt)
+			}
+			if !job.ExpiresAt.Equal(job.FinishedAt.Add(time.Hour)) {
+				t.Errorf("ExpiresAt = %v, want FinishedAt + retention", job.ExpiresAt)
+			}
+		})
+	}
+}
+
+func TestSubmitPanic(t *testing.T) {
+	m := newTestManager(t, 1, 0, time.Hour)
+	job, err := m.Submit(func(context.Context) (interface{}, error) {
+		panic("boom")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	job = waitStatus(t, m, job.ID, Failed)
+	if apperr.CodeOf(job.Err) != apperr.Internal {
+		t.Errorf("panicked job error = %v, want an internal error", job.Err)
+	}
+
+	// 执行名额已释放，后续任务正常执行
+	next, err := m.Submit(func(context.Context) (interface{}, error) { return "ok", nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitStatus(t, m, next.ID, Succeeded)
+}
+
+func TestSubmitQueueFull(t *testing.T) {
+	m := newTestManager(t, 1, 1, time.Hour)
+	running, unblock := blockingJob()
+	defer close(unblock)
+
+	first, err := m.Submit(running.fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-running.started
+	if _, err := m.Submit(running.fn); err != nil {
+		t.Fatalf("Submit into queue: %v", err)
+	}
+
+	_, err = m.Submit(running.fn)
+	var queueFull *limiter.QueueFullError
+	if !errors.As(err, &queueFull) {
+		t.Fatalf("Submit with full queue error = %v, want *limiter.QueueFullError", err)
+	}
+	if queueFull.RetryAfterSeconds() < 1 {
+		t.Errorf("RetryAfterSeconds = %d, want at least 1", queueFull.RetryAfterSeconds())
+	}
+
+	stats := m.Stats()
+	if stats.Running != 1 || stats.Queued != 1 || stats.MaxQueue != 1 {
+		t.Errorf("Stats = %+v, want 1 running, 1 queued, max_queue 1", stats)
+	}
+
+	// 取消执行中的任务后可以再次提交
+	if _, ok := m.Cancel(first.ID); !ok {
+		t.Fatal("Cancel running job not found")
+	}
+	if _, err := m.Submit(running.fn); err != nil {
+		t.Fatalf("Submit after cancel: %v", err)
+	}
+}
+
+func TestCancelRunningJob(t *testing.T) {
+	m := newTestManager(t, 1, 0, time.Hour)
+	canceled := make(chan error, 1)
+	started := make(chan struct{})
+
+	job, err := m.Submit(func(ctx context.Context) (interface{}, error) {
+		close(started)
+		<-ctx.Done()
+		canceled <- ctx.Err()
+		return nil, ctx.Err()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-started
+
+	job, ok := m.Cancel(job.ID)
+	if !ok || job.Status != Canceled {
+		t.Fatalf("Cancel = %s, %v, want %s", job.Status, ok, Canceled)
+	}
+	select {
+	case err := <-canceled:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("job ctx error = %v, want context.Canceled", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("job ctx was not canceled")
+	}
+
+	// 任务返回后保持取消状态
+	time.Sleep(10 * time.Millisecond)
+	if job, _ := m.Get(job.ID); job.Status != Canceled || job.Err != nil {
+		t.Errorf("job after return = %s, %v, want %s without error", job.Status, job.Err, Canceled)
+	}
+}
+
+func TestCancelBeforeStart(t *testing.T) {
+	m := newTestManager(t, 1, 1, time.Hour)
+	running, unblock := blockingJob()
+
+	if _, err := m.Submit(running.fn); err != nil {
+		t.Fatal(err)
+	}
+	<-running.started
+
+	ran := make(chan struct{}, 1)
+	queued, err := m.Submit(func(context.Context) (interface{}, error) {
+		ran <- struct{}{}
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	job, ok := m.Cancel(queued.ID)
+	if !ok || job.Status != Canceled {
+		t.Fatalf("Cancel = %s, %v, want %s", job.Status, ok, Canceled)
+	}
+	if !job.StartedAt.IsZero() {
+		t.Errorf("canceled job StartedAt = %v, want zero", job.StartedAt)
+	}
+
+	close(unblock)
+	select {
+	case <-ran:
+		t.Fatal("job canceled before start was executed")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if stats := m.Stats(); stats.Queued != 0 {
+		t.Errorf("Stats = %+v, want no queued jobs", stats)
+	}
+}
+
+func TestCancelFinishedJobDeletes(t *testing.T) {
+	m := newTestManager(t, 1, 0, time.Hour)
+	job, err := m.Submit(func(context.Context) (interface{}, error) { return nil, nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitStatus(t, m, job.ID, Succeeded)
+
+	if job, ok := m.Cancel(job.ID); !ok || job.Status != Succeeded {
+		t.Fatalf("Cancel finished job = %s, %v, want %s", job.Status, ok, Succeeded)
+	}
+	if _, ok := m.Get(job.ID); ok {
+		t.Error("finished job still exists after Cancel")
+	}
+	if _, ok := m.Cancel(job.ID); ok {
+		t.Error("Cancel of deleted job succeeded")
+	}
+}
+
+func TestRetentionCleanup(t *testing.T) {
+	m := newTestManager(t, 1, 0, 100*time.Millisecond)
+	job, err := m.Submit(func(context.Context) (interface{}, error) { return nil, nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitStatus(t, m, job.ID, Succeeded)
+
+	// 清理间隔至少 1 秒
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		if _, ok := m.Get(job.ID); !ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("finished job was not cleaned up after retention")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestCloseCancelsJobs(t *testing.T) {
+	m := newTestManager(t, 1, 1, time.Hour)
+	running, unblock := blockingJob()
+	defer close(unblock)
+
+	first, err := m.Submit(running.fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-running.started
+	second, err := m.Submit(running.fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.Close()
+	waitStatus(t, m, first.ID, Canceled)
+	waitStatus(t, m, second.ID, Canceled)
+}
+
+// blocking 阻塞直到测试放行的任务
+type blocking struct {
+	started chan struct{}
+	fn      Func
+}
+
+// blockingJob 返回阻塞的任务，第一个任务开始执行时关闭 started，关闭 unblock 后所有任务返回
+func blockingJob() (*blocking, chan struct{}) {
+	unblock := make(chan struct{})
+	b := &blocking{started: make(chan struct{})}
+	var once sync.Once
+	b.fn = func(ctx context.Context) (interface{}, error) {
+		once.Do(func() { close(b.started) })
+		select {
+		case <-unblock:
+		case <-ctx.Done():
+		}
+		return nil, nil
+	}
+	return b, unblock
+}
+
+func newTestManager(t *testing.T, concurrency, maxQueue int, retention time.Duration) *Manager {
+	t.Helper()
+	m, err := NewManager(concurrency, maxQueue, retention)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(m.Close)
+	return m
+}
+
+// waitStatus 等待任务进入指定状态，超过 1 秒时测试失败
+func waitStatus(t *testing.T, m *Manager, id string, status Status) Job {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		job, ok := m.Get(id)
+		if !ok {
+			t.Fatalf("job %s not found", id)
+		}
+		if job.Status == status {
+			return job
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job status = %s, want %s", job.Status, status)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
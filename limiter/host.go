@@ -0,0 +1,255 @@
+package limiter
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 站点状态数量超过该值时清理空闲站点
+const maxIdleHosts = 1000
+
+// HostRule 单个站点的访问限制，0 表示不限制
+type HostRule struct {
+	// RPS 每秒最多发起的导航次数
+	RPS float64
+	// MaxConcurrent 同时打开的标签页数
+	MaxConcurrent int
+	// MinDelay 两次导航之间的最小间隔
+	MinDelay time.Duration
+}
+
+// interval 两次导航开始之间的最小间隔
+func (r HostRule) interval() time.Duration {
+	interval := r.MinDelay
+	if r.RPS > 0 {
+		if perRequest := time.Duration(float64(time.Second) / r.RPS); perRequest > interval {
+			interval = perRequest
+		}
+	}
+	return interval
+}
+
+// HostOverride 配置文件中的站点规则，未设置的字段使用全局默认值
+type HostOverride struct {
+	RPS           *float64       `yaml:"rps"`
+	MaxConcurrent *int           `yaml:"max_concurrent"`
+	MinDelay      *time.Duration `yaml:"min_delay"`
+}
+
+// apply 用覆盖值替换默认规则中的对应字段
+func (o HostOverride) apply(rule HostRule) HostRule {
+	if o.RPS != nil {
+		rule.RPS = *o.RPS
+	}
+	if o.MaxConcurrent != nil {
+		rule.MaxConcurrent = *o.MaxConcurrent
+	}
+	if o.MinDelay != nil {
+		rule.MinDelay = *o.MinDelay
+	}
+	return rule
+}
+
+// LoadHostRules 读取站点规则文件，支持 YAML 和 JSON
+//
+// 文件格式：
+//
+//	hosts:
+//	  example.com:
+//	    rps: 0.5
+//	    max_concurrent: 1
+//	    min_delay: 2s
+func LoadHostRules(path string) (map[string]HostOverride, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取站点规则文件失败: %w", err)
+	}
+
+	var file struct {
+		Hosts map[string]HostOverride `yaml:"hosts"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("解析站点规则文件失败: %w", err)
+	}
+	return file.Hosts, nil
+}
+
+// hostState 单个站点的限流状态
+type hostState struct {
+	// slots 限制同时打开的标签页数，不限制时为 nil
+	slots chan struct{}
+	// next 下一次导航最早的开始时间
+	next time.Time
+	// users 正在等待或占用名额的请求数，不为 0 时不会被清理
+	users int
+}
+
+// HostLimiter 按站点限制访问频率和并发标签页数
+type HostLimiter struct {
+	defaults  HostRule
+	overrides map[string]HostRule
+	hosts     map[string]*hostState
+	mutex     sync.Mutex
+}
+
+// NewHostLimiter 创建站点限流器
+// overrides 的键为域名，同时适用于其子域名
+func NewHostLimiter(defaults HostRule, overrides map[string]HostOverride) (*HostLimiter, error) {
+	l := &HostLimiter{
+		defaults:  defaults,
+		overrides: make(map[string]HostRule, len(overrides)),
+		hosts:     make(map[string]*hostState),
+	}
+	if err := defaults.validate(); err != nil {
+		return nil, fmt.Errorf("全局站点规则无效: %w", err)
+	}
+	for host, override := range overrides {
+		rule := override.apply(defaults)
+		if err := rule.validate(); err != nil {
+			return nil, fmt.Errorf("站点 '%s' 的规则无效: %w", host, err)
+		}
+		l.overrides[strings.ToLower(host)] = rule
+	}
+	return l, nil
+}
+
+// validate 校验规则取值
+func (r HostRule) validate() error {
+	if r.RPS < 0 || r.MaxConcurrent < 0 || r.MinDelay < 0 {
+		return fmt.Errorf("rps、max_concurrent 和 min_delay 不能为负数")
+	}
+	return nil
+}
+
+// Rule 返回站点适用的规则，依次匹配域名本身和上级域名
+func (l *HostLimiter) Rule(host string) HostRule {
+	host = strings.ToLower(host)
+	for {
+		if rule, ok := l.overrides[host]; ok {
+			return rule
+		}
+		i := strings.IndexByte(host, '.')
+		if i < 0 {
+			return l.defaults
+		}
+		host = host[i+1:]
+	}
+}
+
+// Acquire 等待站点的并发名额和导航间隔，返回释放函数和等待时间
+func (l *HostLimiter) Acquire(ctx context.Context, host string) (func(), time.Duration, error) {
+	start := time.Now()
+	rule := l.Rule(host)
+	state := l.state(strings.ToLower(host), rule)
+
+	// 限制同时打开的标签页数，释放前站点状态一直被引用
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			if state.slots != nil {
+				<-state.slots
+			}
+			l.unref(state)
+		})
+	}
+	if state.slots != nil {
+		select {
+		case state.slots <- struct{}{}:
+		case <-ctx.Done():
+			l.unref(state)
+			return nil, time.Since(start), fmt.Errorf("等待站点 %s 的空闲名额超时: %w", host, ctx.Err())
+		}
+	}
+
+	if err := l.wait(ctx, host, rule, state); err != nil {
+		release()
+		return nil, time.Since(start), err
+	}
+	return release, time.Since(start), nil
+}
+
+// Wait 只等待站点的导航间隔，不占用并发名额，返回等待时间
+// 用于已经占用名额的页面在同一站点内再次导航
+func (l *HostLimiter) Wait(ctx context.Context, host string) (time.Duration, error) {
+	start := time.Now()
+	rule := l.Rule(host)
+	state := l.state(strings.ToLower(host), rule)
+	defer l.unref(state)
+
+	err := l.wait(ctx, host, rule, state)
+	return time.Since(start), err
+}
+
+// wait 预约下一次导航的开始时间并等待
+func (l *HostLimiter) wait(ctx context.Context, host string, rule HostRule, state *hostState) error {
+	interval := rule.interval()
+	if interval <= 0 {
+		return nil
+	}
+
+	l.mutex.Lock()
+	at := time.Now()
+	if state.next.After(at) {
+		at = state.next
+	}
+	state.next = at.Add(interval)
+	l.mutex.Unlock()
+
+	wait := time.Until(at)
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("等待站点 %s 的访问间隔超时: %w", host, ctx.Err())
+	}
+}
+
+// state 获取站点状态并增加引用，不存在时创建，使用完后调用 unref
+func (l *HostLimiter) state(host string, rule HostRule) *hostState {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if state, ok := l.hosts[host]; ok {
+		state.users++
+		return state
+	}
+
+	if len(l.hosts) >= maxIdleHosts {
+		l.pruneLocked()
+	}
+
+	state := &hostState{users: 1}
+	if rule.MaxConcurrent > 0 {
+		state.slots = make(chan struct{}, rule.MaxConcurrent)
+	}
+	l.hosts[host] = state
+	return state
+}
+
+// unref 减少站点状态的引用
+func (l *HostLimiter) unref(state *hostState) {
+	l.mutex.Lock()
+	state.users--
+	l.mutex.Unlock()
+}
+
+// pruneLocked 清理没有等待或进行中请求且间隔已过的站点，调用方需持有锁
+func (l *HostLimiter) pruneLocked() {
+	now := time.Now()
+	for host, state := range l.hosts {
+		if state.users == 0 && state.next.Before(now) {
+			delete(l.hosts, host)
+		}
+	}
+}
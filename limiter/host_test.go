@@ -0,0 +1,207 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHostRuleInterval(t *testing.T) {
+	tests := []struct {
+		name string
+		rule HostRule
+		want time.Duration
+	}{
+		{"unlimited", HostRule{}, 0},
+		{"rps", HostRule{RPS: 2}, 500 * time.Millisecond},
+		{"min delay", HostRule{MinDelay: time.Second}, time.Second},
+		{"rps slower than delay", HostRule{RPS: 0.5, MinDelay: time.Second}, 2 * time.Second},
+		{"delay slower than rps", HostRule{RPS: 10, MinDelay: time.Second}, time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rule.interval(); got != tt.want {
+				t.Errorf("interval() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHostLimiterRule(t *testing.T) {
+	one, three := 1, 3
+	delay := 3 * time.Second
+	defaults := HostRule{RPS: 1}
+	l, err := NewHostLimiter(defaults, map[string]HostOverride{
+		"Example.com":      {MaxConcurrent: &one},
+		"news.example.com": {MaxConcurrent: &three, MinDelay: &delay},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		host string
+		want HostRule
+	}{
+		{"example.com", HostRule{RPS: 1, MaxConcurrent: 1}},
+		{"WWW.example.com", HostRule{RPS: 1, MaxConcurrent: 1}},
+		{"news.example.com", HostRule{RPS: 1, MaxConcurrent: 3, MinDelay: delay}},
+		{"a.news.example.com", HostRule{RPS: 1, MaxConcurrent: 3, MinDelay: delay}},
+		{"example.org", defaults},
+		{"", defaults},
+	}
+	for _, tt := range tests {
+		if got := l.Rule(tt.host); got != tt.want {
+			t.Errorf("Rule(%q) = %+v, want %+v", tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestNewHostLimiterInvalid(t *testing.T) {
+	negative := -1
+	tests := []struct {
+		name      string
+		defaults  HostRule
+		overrides map[string]HostOverride
+	}{
+		{"negative defaults", HostRule{RPS: -1}, nil},
+		{"negative override", HostRule{}, map[string]HostOverride{"example.com": {MaxConcurrent: &negative}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewHostLimiter(tt.defaults, tt.overrides); err == nil {
+				t.Fatal("NewHostLimiter succeeded, want error")
+			}
+		})
+	}
+}
+
+func TestLoadHostRules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hosts.yaml")
+	data := "hosts:\n  example.com:\n    rps: 0.5\n    max_concurrent: 1\n    min_delay: 2s\n"
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	rules, err := LoadHostRules(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rule := rules["example.com"].apply(HostRule{RPS: 5, MaxConcurrent: 10})
+	want := HostRule{RPS: 0.5, MaxConcurrent: 1, MinDelay: 2 * time.Second}
+	if rule != want {
+		t.Errorf("rule = %+v, want %+v", rule, want)
+	}
+}
+
+func TestHostLimiterMaxConcurrent(t *testing.T) {
+	l, err := NewHostLimiter(HostRule{MaxConcurrent: 1}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	release, _, err := l.Acquire(context.Background(), "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 其他站点不受影响
+	other, _, err := l.Acquire(context.Background(), "example.org")
+	if err != nil {
+		t.Fatalf("Acquire other host: %v", err)
+	}
+	other()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, _, err := l.Acquire(ctx, "EXAMPLE.com"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Acquire while slot is held error = %v, want context.DeadlineExceeded", err)
+	}
+
+	// Wait 不占用并发名额
+	if _, err := l.Wait(context.Background(), "example.com"); err != nil {
+		t.Fatalf("Wait while slot is held: %v", err)
+	}
+
+	release()
+	release, _, err = l.Acquire(context.Background(), "example.com")
+	if err != nil {
+		t.Fatalf("Acquire after release: %v", err)
+	}
+	release()
+}
+
+func TestHostLimiterInterval(t *testing.T) {
+	const delay = 50 * time.Millisecond
+	l, err := NewHostLimiter(HostRule{MinDelay: delay}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	release, waited, err := l.Acquire(context.Background(), "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+	if waited >= delay {
+		t.Errorf("first Acquire waited %v, want less than %v", waited, delay)
+	}
+
+	waited, err = l.Wait(context.Background(), "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if waited < delay/2 {
+		t.Errorf("second navigation waited %v, want about %v", waited, delay)
+	}
+
+	// 等待间隔时 ctx 结束返回错误并释放名额
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, err := l.Acquire(ctx, "example.com"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Acquire with canceled ctx error = %v, want context.Canceled", err)
+	}
+}
+
+func TestHostLimiterPruneKeepsReferencedHosts(t *testing.T) {
+	l, err := NewHostLimiter(HostRule{MaxConcurrent: 1}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	release, _, err := l.Acquire(context.Background(), "held.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 超过上限后创建新站点会清理空闲站点
+	for i := 0; i <= maxIdleHosts; i++ {
+		r, _, err := l.Acquire(context.Background(), fmt.Sprintf("host%d.example.com", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		r()
+	}
+
+	l.mutex.Lock()
+	_, kept := l.hosts["held.example.com"]
+	size := len(l.hosts)
+	l.mutex.Unlock()
+	if !kept {
+		t.Fatal("prune removed a host whose slot is still held")
+	}
+	if size > maxIdleHosts {
+		t.Errorf("hosts = %d after prune, want at most %d", size, maxIdleHosts)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, _, err := l.Acquire(ctx, "held.example.com"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Acquire after prune error = %v, want the held slot to still block", err)
+	}
+	release()
+}
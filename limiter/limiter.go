@@ -0,0 +1,127 @@
+package limiter
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 没有历史数据时估算的单个请求耗时
+const defaultDuration = 5 * time.Second
+
+// QueueFullError 等待队列已满
+type QueueFullError struct {
+	// RetryAfter 建议的重试等待时间
+	RetryAfter time.Duration
+}
+
+func (e *QueueFullError) Error() string {
+	return fmt.Sprintf("等待队列已满，请在 %v 后重试", e.RetryAfter)
+}
+
+// RetryAfterSeconds 返回 Retry-After 响应头使用的秒数，至少为 1
+func (e *QueueFullError) RetryAfterSeconds() int {
+	return int(math.Max(1, math.Ceil(e.RetryAfter.Seconds())))
+}
+
+// Stats 限流器状态
+type Stats struct {
+	Active      int `json:"active"`
+	Queued      int `json:"queued"`
+	Concurrency int `json:"concurrency"`
+	MaxQueue    int `json:"max_queue"`
+}
+
+// Limiter 并发限制器，超过并发数的请求进入有界队列等待
+type Limiter struct {
+	slots    chan struct{}
+	maxQueue int64
+	queued   int64
+
+	// avg 请求平均耗时，用于估算 Retry-After
+	avg   time.Duration
+	mutex sync.Mutex
+}
+
+// New 创建并发限制器
+// concurrency 为同时执行的最大请求数，maxQueue 为等待队列长度，0 表示不排队
+func New(concurrency, maxQueue int) (*Limiter, error) {
+	if concurrency < 1 {
+		return nil, fmt.Errorf("并发数必须大于 0")
+	}
+	if maxQueue < 0 {
+		return nil, fmt.Errorf("队列长度不能为负数")
+	}
+	return &Limiter{
+		slots:    make(chan struct{}, concurrency),
+		maxQueue: int64(maxQueue),
+		avg:      defaultDuration,
+	}, nil
+}
+
+// Acquire 获取执行名额，返回释放函数和排队时间
+// 队列已满时返回 *QueueFullError，ctx 结束时返回 ctx 的错误
+func (l *Limiter) Acquire(ctx context.Context) (func(), time.Duration, error) {
+	start := time.Now()
+
+	// 有空闲名额时直接执行
+	select {
+	case l.slots <- struct{}{}:
+		return l.release(time.Now()), 0, nil
+	default:
+	}
+
+	if atomic.AddInt64(&l.queued, 1) > l.maxQueue {
+		atomic.AddInt64(&l.queued, -1)
+		return nil, 0, &QueueFullError{RetryAfter: l.retryAfter()}
+	}
+	defer atomic.AddInt64(&l.queued, -1)
+
+	select {
+	case l.slots <- struct{}{}:
+		return l.release(time.Now()), time.Since(start), nil
+	case <-ctx.Done():
+		return nil, time.Since(start), fmt.Errorf("排队等待超时: %w", ctx.Err())
+	}
+}
+
+// Stats 返回限流器状态
+func (l *Limiter) Stats() Stats {
+	return Stats{
+		Active:      len(l.slots),
+		Queued:      int(atomic.LoadInt64(&l.queued)),
+		Concurrency: cap(l.slots),
+		MaxQueue:    int(l.maxQueue),
+	}
+}
+
+// release 返回释放名额的函数，同时记录请求耗时
+func (l *Limiter) release(acquired time.Time) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.record(time.Since(acquired))
+			<-l.slots
+		})
+	}
+}
+
+// record 按指数移动平均更新请求平均耗时
+func (l *Limiter) record(d time.Duration) {
+	l.mutex.Lock()
+	l.avg = (l.avg*4 + d) / 5
+	l.mutex.Unlock()
+}
+
+// retryAfter 按平均耗时估算队列清空所需时间
+func (l *Limiter) retryAfter() time.Duration {
+	l.mutex.Lock()
+	avg := l.avg
+	l.mutex.Unlock()
+
+	rounds := float64(atomic.LoadInt64(&l.queued)+1) / float64(cap(l.slots))
+	return time.Duration(math.Ceil(rounds) * float64(avg))
+}
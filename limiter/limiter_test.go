@@ -0,0 +1,153 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name        string
+		concurrency int
+		maxQueue    int
+		wantErr     bool
+	}{
+		{"valid", 2, 10, false},
+		{"no queue", 1, 0, false},
+		{"zero concurrency", 0, 10, true},
+		{"negative queue", 1, -1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.concurrency, tt.maxQueue)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("New(%d, %d) error = %v, wantErr %v", tt.concurrency, tt.maxQueue, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRetryAfterSeconds(t *testing.T) {
+	tests := []struct {
+		retryAfter time.Duration
+		want       int
+	}{
+		{0, 1},
+		{300 * time.Millisecond, 1},
+		{time.Second, 1},
+		{1500 * time.Millisecond, 2},
+		{10 * time.Second, 10},
+	}
+	for _, tt := range tests {
+		err := &QueueFullError{RetryAfter: tt.retryAfter}
+		if got := err.RetryAfterSeconds(); got != tt.want {
+			t.Errorf("RetryAfterSeconds(%v) = %d, want %d", tt.retryAfter, got, tt.want)
+		}
+	}
+}
+
+func TestAcquireQueueFull(t *testing.T) {
+	l, err := New(1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	release, queued, err := l.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("first Acquire: %v", err)
+	}
+	if queued != 0 {
+		t.Errorf("first Acquire queued %v, want 0", queued)
+	}
+
+	// 第二个请求进入队列
+	acquired := make(chan func(), 1)
+	go func() {
+		release, _, err := l.Acquire(context.Background())
+		if err != nil {
+			t.Errorf("queued Acquire: %v", err)
+		}
+		acquired <- release
+	}()
+	waitFor(t, func() bool { return l.Stats().Queued == 1 })
+
+	// 队列已满时立即返回 QueueFullError
+	_, _, err = l.Acquire(context.Background())
+	var queueFull *QueueFullError
+	if !errors.As(err, &queueFull) {
+		t.Fatalf("Acquire with full queue error = %v, want *QueueFullError", err)
+	}
+	if queueFull.RetryAfter <= 0 {
+		t.Errorf("RetryAfter = %v, want > 0", queueFull.RetryAfter)
+	}
+
+	release()
+	select {
+	case release := <-acquired:
+		release()
+	case <-time.After(time.Second):
+		t.Fatal("queued Acquire did not get a slot after release")
+	}
+
+	stats := l.Stats()
+	if stats.Active != 0 || stats.Queued != 0 {
+		t.Errorf("Stats after release = %+v, want no active or queued requests", stats)
+	}
+}
+
+func TestAcquireContextCanceled(t *testing.T) {
+	l, err := New(1, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	release, _, err := l.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, _, err := l.Acquire(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Acquire error = %v, want context.DeadlineExceeded", err)
+	}
+	if queued := l.Stats().Queued; queued != 0 {
+		t.Errorf("Queued after timeout = %d, want 0", queued)
+	}
+}
+
+func TestReleaseIsIdempotent(t *testing.T) {
+	l, err := New(1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	release, _, err := l.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+	release()
+
+	if active := l.Stats().Active; active != 0 {
+		t.Fatalf("Active after double release = %d, want 0", active)
+	}
+	release, _, err = l.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("Acquire after release: %v", err)
+	}
+	release()
+}
+
+// waitFor 等待条件成立，超过 1 秒时测试失败
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met within 1s")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
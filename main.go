@@ -1,32 +1,45 @@
 package main
 
 import (
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
 	"time"
 
+	"textsurf/apperr"
+	"textsurf/jobs"
+	"textsurf/limiter"
 	"textsurf/modules"
 	"textsurf/modules/baichuanweb"
 	"textsurf/modules/baidu"
 	"textsurf/modules/daxuesoutijiang"
+	"textsurf/modules/declarative"
+	"textsurf/pool"
 	"textsurf/sessions"
+	"textsurf/webhook"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-rod/rod"
 	"github.com/go-rod/rod/lib/launcher"
-	"github.com/go-rod/rod/lib/proto"
-	"github.com/go-rod/stealth"
 	"github.com/urfave/cli/v2"
 )
 
 // 全局变量存储配置
 var (
-	browser        *rod.Browser
-	moduleRegistry *modules.ModuleRegistry
-	sessionManager *sessions.Manager
-	config         Config // 添加这行来存储全局配置
+	browser         *rod.Browser
+	moduleRegistry  *modules.ModuleRegistry
+	sessionManager  *sessions.Manager
+	credentialStore *sessions.CredentialStore
+	pagePool        *pool.Pool
+	fetchLimiter    *limiter.Limiter
+	hostLimiter     *limiter.HostLimiter
+	jobManager      *jobs.Manager
+	webhookSender   *webhook.Sender
+	config          Config // 添加这行来存储全局配置
 )
 
 // 配置结构体
@@ -34,10 +47,27 @@ type Config struct {
 	Port     string
 	Headless bool
 	Debug    bool
+	Timeout  time.Duration // 请求默认超时，0 表示不限制
+	PoolMin  int           // 页面池预热的页面数
+	PoolMax  int           // 页面池最大页面数
+	// SessionMode 登录会话的浏览器模式：incognito 共享浏览器，process 每个会话独立进程
+	SessionMode string
+	// MaxConcurrency 同时执行的 /fetch 请求数，MaxQueue 等待队列长度
+	MaxConcurrency int
+	MaxQueue       int
+	// HostRule 每个站点的默认访问限制，HostRulesFile 为按域名覆盖的规则文件
+	HostRule      limiter.HostRule
+	HostRulesFile string
+	// JobRetention 异步任务结束后结果的保留时间
+	JobRetention time.Duration
+	// WebhookSecret 登录回调的签名密钥
+	WebhookSecret string
+	// ModulesDir 声明式模块定义文件所在目录
+	ModulesDir string
 }
 
-// 初始化模块注册表
-func initModuleRegistry() {
+// 初始化模块注册表，modulesDir 不为空时同时加载其中的声明式模块
+func initModuleRegistry(modulesDir string) error {
 	moduleRegistry = modules.NewModuleRegistry()
 
 	// 注册百度模块
@@ -52,13 +82,38 @@ func initModuleRegistry() {
 	baichuanwebModule := baichuanweb.NewBaichuanwebModule()
 	moduleRegistry.Register(baichuanwebModule)
 
+	// 加载声明式模块，不允许覆盖内置模块
+	if modulesDir != "" {
+		loaded, err := declarative.Load(modulesDir)
+		if err != nil {
+			return err
+		}
+		for _, module := range loaded {
+			if _, exists := moduleRegistry.Get(module.Name()); exists {
+				return fmt.Errorf("声明式模块 '%s' 与内置模块重名", module.Name())
+			}
+			moduleRegistry.Register(module)
+			fmt.Printf("Declarative module loaded: %s %v\n", module.Name(), modules.Capabilities(module))
+		}
+	}
+
 	fmt.Println("Module registry initialized")
+	return nil
 }
 
 // 初始化会话管理器
-func initSessionManager() {
-	sessionManager = sessions.NewManager()
-	fmt.Println("Session manager initialized")
+func initSessionManager(mode string) error {
+	switch mode {
+	case sessions.ModeIncognito:
+		sessionManager = sessions.NewManager(browser)
+	case sessions.ModeProcess:
+		sessionManager = sessions.NewManager(nil)
+	default:
+		return fmt.Errorf("invalid session mode '%s', use '%s' or '%s'", mode, sessions.ModeIncognito, sessions.ModeProcess)
+	}
+	credentialStore = sessions.NewCredentialStore()
+	fmt.Printf("Session manager initialized (mode: %s)\n", mode)
+	return nil
 }
 
 // 初始化浏览器实例
@@ -74,141 +129,109 @@ func initBrowser(headless bool) {
 	fmt.Printf("Browser initialized successfully (headless: %v, stealth: enabled)\n", headless)
 }
 
+// 初始化页面池
+func initPagePool(min, max int) error {
+	p, err := pool.New(browser, min, max)
+	if err != nil {
+		return err
+	}
+	pagePool = p
+	fmt.Printf("Page pool initialized (min: %d, max: %d)\n", min, max)
+	return nil
+}
+
+// 初始化站点访问限制，规则文件中的域名覆盖全局默认值
+func initHostLimiter(defaults limiter.HostRule, rulesFile string) error {
+	var overrides map[string]limiter.HostOverride
+	if rulesFile != "" {
+		var err error
+		overrides, err = limiter.LoadHostRules(rulesFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	l, err := limiter.NewHostLimiter(defaults, overrides)
+	if err != nil {
+		return err
+	}
+	hostLimiter = l
+	fmt.Printf("Host rate limit initialized (rps: %v, concurrency: %d, delay: %v, overrides: %d)\n",
+		defaults.RPS, defaults.MaxConcurrent, defaults.MinDelay, len(overrides))
+	return nil
+}
+
 // 清理浏览器资源
 func closeBrowser() {
+	if jobManager != nil {
+		jobManager.Close()
+	}
+	if pagePool != nil {
+		pagePool.Close()
+	}
 	if browser != nil {
 		browser.MustClose()
 		fmt.Println("Browser closed")
 	}
 }
 
-// API 处理函数
-func handleRequest(c *gin.Context) {
-	// 获取返回类型（text 或 html）
-	returnType := c.Param("type")
-	if returnType != "text" && returnType != "html" {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid return type. Use 'text' or 'html'",
-		})
-		return
+// respondError 按错误码返回 JSON 错误响应
+func respondError(c *gin.Context, err error) {
+	// 队列已满时告知客户端何时重试
+	var queueFull *limiter.QueueFullError
+	if errors.As(err, &queueFull) {
+		c.Header("Retry-After", strconv.Itoa(queueFull.RetryAfterSeconds()))
 	}
 
-	// 获取必需的 URL 参数
-	targetURL := c.Query("url")
-	if targetURL == "" {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Missing required parameter: url",
-		})
-		return
-	}
+	c.JSON(apperr.Status(err), errorBody(err))
+}
 
-	// 获取可选参数
-	cssPath := c.Query("css_path")
-	clickCssPath := c.Query("click_css_path")
+// errorBody 错误响应的 JSON 内容
+func errorBody(err error) gin.H {
+	return gin.H{
+		"error":     err.Error(),
+		"code":      apperr.CodeOf(err),
+		"retryable": apperr.Retryable(err),
+	}
+}
 
-	// 创建新页面（使用 stealth 反检测）
-	page, pageErr := stealth.Page(browser)
-	if pageErr != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": fmt.Sprintf("Failed to create stealth page: %v", pageErr),
-		})
+// API 处理函数
+func handleRequest(c *gin.Context) {
+	// 读取并校验请求参数
+	req, err := bindFetchRequest(c)
+	if err != nil {
+		respondError(c, apperr.From(err, apperr.InvalidRequest))
 		return
 	}
-	defer page.MustClose()
 
-	// 设置 User-Agent
-	page.MustSetUserAgent(&proto.NetworkSetUserAgentOverride{
-		UserAgent: "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
-	})
-
-	// 导航到目标页面
-	page.MustNavigate(targetURL)
-
-	// 等待页面加载完成
-	page.MustWaitStable()
-	time.Sleep(1 * time.Second) // 额外等待确保页面完全加载
-
-	// 如果提供了点击路径，先执行点击操作
-	if clickCssPath != "" {
-		fmt.Printf("Attempting to click element with CSS path: %s\n", clickCssPath)
-		clickElement, err := page.Element(clickCssPath)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": fmt.Sprintf("Error finding click element with CSS path '%s': %v", clickCssPath, err),
-			})
-			return
-		}
-
-		err = clickElement.Click(proto.InputMouseButtonLeft, 1)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": fmt.Sprintf("Error clicking element: %v", err),
-			})
-			return
-		}
+	// 页面操作绑定请求上下文，客户端断开或超时后立即中断
+	ctx, cancel := requestContext(c, req.Timeout)
+	defer cancel()
 
-		// 等待点击后的内容加载
-		time.Sleep(2 * time.Second)
-		page.MustWaitStable()
-		fmt.Println("Successfully clicked element")
+	if err := req.loadCookies(ctx); err != nil {
+		respondError(c, apperr.From(err, apperr.InvalidRequest))
+		return
 	}
 
-	var content string
-	var err error
-
-	// 根据是否提供 CSS 路径来获取内容
-	if cssPath != "" {
-		// 获取指定 CSS 路径的内容
-		fmt.Printf("Getting content from CSS path: %s\n", cssPath)
-		element, err := page.Element(cssPath)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": fmt.Sprintf("Error finding element with CSS path '%s': %v", cssPath, err),
-			})
-			return
-		}
-
-		if returnType == "text" {
-			content, err = element.Text()
-		} else {
-			content, err = element.HTML()
+	result, err := runFetch(ctx, req)
+	if err != nil {
+		if apperr.CodeOf(err) == apperr.ClientClosed {
+			fmt.Printf("Client disconnected, fetch of %s aborted\n", req.URL)
 		}
+		respondError(c, err)
+		return
+	}
 
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": fmt.Sprintf("Error getting %s content from element: %v", returnType, err),
-			})
-			return
-		}
-	} else {
-		// 获取整个页面的内容
-		fmt.Println("Getting full page content")
-		bodyElement := page.MustElement("body")
-
-		if returnType == "text" {
-			content, err = bodyElement.Text()
-		} else {
-			content, err = bodyElement.HTML()
-		}
+	c.Header("X-Queue-Time", strconv.FormatInt(result.QueueMs, 10))
 
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": fmt.Sprintf("Error getting page %s content: %v", returnType, err),
-			})
-			return
-		}
+	// 截图和 PDF 直接返回二进制内容
+	if result.Data != nil {
+		c.Data(http.StatusOK, result.ContentType, result.Data)
+		return
 	}
 
 	// 返回结果
-	response := gin.H{
-		"url":            targetURL,
-		"type":           returnType,
-		"content":        content,
-		"css_path":       cssPath,
-		"click_css_path": clickCssPath,
-	}
-
-	c.JSON(http.StatusOK, response)
+	c.JSON(http.StatusOK, result)
 }
 
 // 创建会话
@@ -218,26 +241,53 @@ func handleCreateSession(c *gin.Context) {
 	// 获取模块
 	module, exists := moduleRegistry.Get(moduleName)
 	if !exists {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": fmt.Sprintf("Module '%s' not found", moduleName),
-		})
+		respondError(c, apperr.New(apperr.NotFound, "Module '%s' not found", moduleName))
 		return
 	}
 
+	// 可选的回调地址，登录完成、失败或过期时通知
+	var req struct {
+		CallbackURL string `json:"callback_url"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondError(c, apperr.New(apperr.InvalidRequest, "Invalid request body: %v", err))
+			return
+		}
+	}
+	if req.CallbackURL != "" {
+		if config.WebhookSecret == "" {
+			respondError(c, apperr.New(apperr.InvalidRequest, "Webhook secret is not configured. Start the server with --webhook-secret to use callback_url"))
+			return
+		}
+		if err := webhook.ValidateURL(req.CallbackURL); err != nil {
+			respondError(c, apperr.New(apperr.InvalidRequest, "Invalid callback_url: %v", err))
+			return
+		}
+	}
+
 	// 创建会话
 	session, err := sessionManager.CreateSession(module, config.Headless) // 使用全局配置的 headless 设置
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": fmt.Sprintf("Failed to create session: %v", err),
-		})
+		respondError(c, apperr.Wrap(err, apperr.Internal, "Failed to create session"))
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	response := gin.H{
 		"session_id": session.ID,
 		"module":     moduleName,
 		"created_at": session.CreatedAt,
-	})
+		"expires_at": session.CreatedAt.Add(sessions.TTL),
+	}
+
+	// 由服务端轮询登录状态并回调，客户端无需轮询 check_login
+	// 轮询在获取二维码、准备短信登录或提交账号密码后才开始，此时会话页面还是空白页
+	if req.CallbackURL != "" {
+		go notifyLogin(session, req.CallbackURL)
+		response["callback_url"] = req.CallbackURL
+	}
+
+	c.JSON(http.StatusOK, response)
 }
 
 // 获取登录二维码
@@ -251,36 +301,147 @@ func handleGetLoginQRCode(c *gin.Context) {
 	session, exists := sessionManager.GetSession(sessionID)
 	if !exists {
 		log.Printf("会话不存在: %s\n", sessionID)
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": fmt.Sprintf("Session '%s' not found", sessionID),
-		})
+		respondError(c, apperr.New(apperr.NotFound, "Session '%s' not found", sessionID))
 		return
 	}
 
 	// 验证模块
 	if session.Module.Name() != moduleName {
 		log.Printf("模块不匹配: session.module=%s, requested_module=%s\n", session.Module.Name(), moduleName)
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": fmt.Sprintf("Session does not belong to module '%s'", moduleName),
-		})
+		respondError(c, apperr.New(apperr.InvalidRequest, "Session does not belong to module '%s'", moduleName))
+		return
+	}
+
+	qrModule, err := qrLogin(session.Module)
+	if err != nil {
+		respondError(c, err)
 		return
 	}
 
 	log.Printf("调用模块 %s 的 GetLoginQRCodeImage 方法\n", moduleName)
 	// 获取二维码图片内容
-	qrCodeImage, err := session.Module.GetLoginQRCodeImage(session)
+	var qrCodeImage []byte
+	err = runModule(c, session, func(session *modules.Session) (err error) {
+		qrCodeImage, err = qrModule.GetLoginQRCodeImage(session)
+		return err
+	})
 	if err != nil {
 		log.Printf("获取二维码失败: %v\n", err)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": fmt.Sprintf("Failed to get QR code: %v", err),
-		})
+		respondError(c, apperr.Wrap(err, apperr.Internal, "Failed to get QR code"))
 		return
 	}
 
+	// 监听登录状态，二维码过期时自动刷新
+	startLoginWatch(session)
+	if qr, ok := session.QR.Current(); ok {
+		c.Header("X-QR-Version", strconv.Itoa(qr.Version))
+		c.Header("X-QR-Expires-At", qr.ExpiresAt.UTC().Format(http.TimeFormat))
+	}
+
 	// 返回图片内容
 	c.Data(http.StatusOK, "image/png", qrCodeImage)
 }
 
+// 获取二维码解码后的内容和 base64 编码的图片
+// 客户端可以用解码内容自行绘制二维码、生成移动端跳转链接或在终端显示
+func handleGetLoginQRPayload(c *gin.Context) {
+	sessionID := c.Param("session_id")
+	moduleName := c.Param("module")
+
+	// 获取会话
+	session, exists := sessionManager.GetSession(sessionID)
+	if !exists {
+		respondError(c, apperr.New(apperr.NotFound, "Session '%s' not found", sessionID))
+		return
+	}
+
+	// 验证模块
+	if session.Module.Name() != moduleName {
+		respondError(c, apperr.New(apperr.InvalidRequest, "Session does not belong to module '%s'", moduleName))
+		return
+	}
+
+	qrModule, err := qrLogin(session.Module)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	// 获取二维码图片内容
+	var qrCodeImage []byte
+	err = runModule(c, session, func(session *modules.Session) (err error) {
+		qrCodeImage, err = qrModule.GetLoginQRCodeImage(session)
+		return err
+	})
+	if err != nil {
+		log.Printf("获取二维码失败: %v\n", err)
+		respondError(c, apperr.Wrap(err, apperr.Internal, "Failed to get QR code"))
+		return
+	}
+
+	// 监听登录状态，二维码过期时自动刷新
+	startLoginWatch(session)
+
+	response := gin.H{
+		"session_id":   sessionID,
+		"module":       moduleName,
+		"image":        base64.StdEncoding.EncodeToString(qrCodeImage),
+		"content_type": http.DetectContentType(qrCodeImage),
+	}
+	if qr, ok := session.QR.Current(); ok {
+		response["version"] = qr.Version
+		response["expires_at"] = qr.ExpiresAt
+	}
+
+	// 解码失败时仍然返回图片，由客户端直接显示
+	payload, err := modules.DecodeQRCode(qrCodeImage)
+	if err != nil {
+		log.Printf("解码二维码失败: %v\n", err)
+		response["payload"] = nil
+		response["decode_error"] = err.Error()
+	} else {
+		response["payload"] = payload
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// 查询当前二维码的版本和有效期
+func handleQRStatus(c *gin.Context) {
+	sessionID := c.Param("session_id")
+	moduleName := c.Param("module")
+
+	// 获取会话
+	session, exists := sessionManager.GetSession(sessionID)
+	if !exists {
+		respondError(c, apperr.New(apperr.NotFound, "Session '%s' not found", sessionID))
+		return
+	}
+
+	// 验证模块
+	if session.Module.Name() != moduleName {
+		respondError(c, apperr.New(apperr.InvalidRequest, "Session does not belong to module '%s'", moduleName))
+		return
+	}
+
+	qr, ok := session.QR.Current()
+	if !ok {
+		respondError(c, apperr.New(apperr.NotFound, "QR code has not been generated. Call login_img first"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"session_id": sessionID,
+		"module":     moduleName,
+		"version":    qr.Version,
+		"url":        qr.URL,
+		"payload":    qr.Payload,
+		"fetched_at": qr.FetchedAt,
+		"expires_at": qr.ExpiresAt,
+		"expired":    qr.Expired(),
+	})
+}
+
 // 检查登录状态
 func handleCheckLogin(c *gin.Context) {
 	sessionID := c.Param("session_id")
@@ -289,28 +450,30 @@ func handleCheckLogin(c *gin.Context) {
 	// 获取会话
 	session, exists := sessionManager.GetSession(sessionID)
 	if !exists {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": fmt.Sprintf("Session '%s' not found", sessionID),
-		})
+		respondError(c, apperr.New(apperr.NotFound, "Session '%s' not found", sessionID))
 		return
 	}
 
 	// 验证模块
 	if session.Module.Name() != moduleName {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": fmt.Sprintf("Session does not belong to module '%s'", moduleName),
-		})
+		respondError(c, apperr.New(apperr.InvalidRequest, "Session does not belong to module '%s'", moduleName))
 		return
 	}
 
 	// 检查登录状态
-	loggedIn, _, err := session.Module.CheckLogin(session)
+	var loggedIn bool
+	var cookies map[string]string
+	err := runModule(c, session, func(session *modules.Session) (err error) {
+		loggedIn, cookies, err = session.Module.CheckLogin(session)
+		return err
+	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": fmt.Sprintf("Failed to check login status: %v", err),
-		})
+		respondError(c, apperr.Wrap(err, apperr.Internal, "Failed to check login status"))
 		return
 	}
+	if loggedIn {
+		publishLoggedIn(session, cookies)
+	}
 
 	// 返回登录状态
 	c.JSON(http.StatusOK, gin.H{
@@ -328,46 +491,61 @@ func handleGetCookies(c *gin.Context) {
 	// 获取会话
 	session, exists := sessionManager.GetSession(sessionID)
 	if !exists {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": fmt.Sprintf("Session '%s' not found", sessionID),
-		})
+		respondError(c, apperr.New(apperr.NotFound, "Session '%s' not found", sessionID))
 		return
 	}
 
 	// 验证模块
 	if session.Module.Name() != moduleName {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": fmt.Sprintf("Session does not belong to module '%s'", moduleName),
-		})
+		respondError(c, apperr.New(apperr.InvalidRequest, "Session does not belong to module '%s'", moduleName))
 		return
 	}
 
 	// 检查登录状态
-	loggedIn, cookies, err := session.Module.CheckLogin(session)
+	var loggedIn bool
+	var cookies map[string]string
+	err := runModule(c, session, func(session *modules.Session) (err error) {
+		loggedIn, cookies, err = session.Module.CheckLogin(session)
+		return err
+	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": fmt.Sprintf("Failed to check login status: %v", err),
-		})
+		respondError(c, apperr.Wrap(err, apperr.Internal, "Failed to check login status"))
 		return
 	}
 
 	if loggedIn {
+		// 先发布登录成功事件，删除会话时不会再发送过期事件
+		publishLoggedIn(session, cookies)
+
 		// 登录成功，转换cookies为分号分隔的字符串
-		cookieStr := ""
-		for name, value := range cookies {
-			if cookieStr != "" {
-				cookieStr += "; "
+		response := gin.H{
+			"cookies": cookieString(cookies),
+		}
+
+		// 保存为命名凭证，供 /fetch 的 credential 参数使用
+		if name := c.Query("save_as"); name != "" {
+			browserCookies, err := session.Browser.GetCookies()
+			if err != nil {
+				respondError(c, apperr.Wrap(err, apperr.Internal, "Failed to read session cookies"))
+				return
 			}
-			cookieStr += name + "=" + value
+			credentialStore.Save(name, moduleName, browserCookies)
+			response["credential"] = name
 		}
 
-		// 返回cookies并关闭会话
-		c.JSON(http.StatusOK, gin.H{
-			"cookies": cookieStr,
-		})
+		// keep_session=true 时保留会话，供 /fetch 的 session_id 参数使用
+		keepSession := c.Query("keep_session") == "true"
+		if keepSession {
+			response["session_id"] = sessionID
+		}
+
+		// 返回cookies
+		c.JSON(http.StatusOK, response)
 
 		// 关闭会话
-		sessionManager.DeleteSession(sessionID)
+		if !keepSession {
+			sessionManager.DeleteSession(sessionID)
+		}
 	} else {
 		// 仍在等待登录
 		c.JSON(http.StatusOK, gin.H{
@@ -379,6 +557,39 @@ func handleGetCookies(c *gin.Context) {
 	}
 }
 
+// cookieString 把 cookies 转换为 Cookie 头格式的字符串
+func cookieString(cookies map[string]string) string {
+	cookieStr := ""
+	for name, value := range cookies {
+		if cookieStr != "" {
+			cookieStr += "; "
+		}
+		cookieStr += name + "=" + value
+	}
+	return cookieStr
+}
+
+// 列出已保存的命名凭证
+func handleListCredentials(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"credentials": credentialStore.List(),
+	})
+}
+
+// 删除命名凭证
+func handleDeleteCredential(c *gin.Context) {
+	name := c.Param("name")
+	if !credentialStore.Delete(name) {
+		respondError(c, apperr.New(apperr.NotFound, "Credential '%s' not found", name))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"name":    name,
+		"deleted": true,
+	})
+}
+
 // 准备短信登录页面
 func handlePrepareSMSLogin(c *gin.Context) {
 	sessionID := c.Param("session_id")
@@ -389,28 +600,33 @@ func handlePrepareSMSLogin(c *gin.Context) {
 	session, exists := sessionManager.GetSession(sessionID)
 	if !exists {
 		log.Printf("会话不存在: %s\n", sessionID)
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": fmt.Sprintf("Session '%s' not found", sessionID),
-		})
+		respondError(c, apperr.New(apperr.NotFound, "Session '%s' not found", sessionID))
 		return
 	}
 
 	if session.Module.Name() != moduleName {
 		log.Printf("模块不匹配: session.module=%s, requested_module=%s\n", session.Module.Name(), moduleName)
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": fmt.Sprintf("Session does not belong to module '%s'", moduleName),
-		})
+		respondError(c, apperr.New(apperr.InvalidRequest, "Session does not belong to module '%s'", moduleName))
 		return
 	}
 
-	info, err := session.Module.PrepareSMSLogin(session)
+	smsModule, err := smsLogin(session.Module)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	var info map[string]interface{}
+	err = runModule(c, session, func(session *modules.Session) (err error) {
+		info, err = smsModule.PrepareSMSLogin(session)
+		return err
+	})
 	if err != nil {
 		log.Printf("准备短信登录失败: %v\n", err)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": fmt.Sprintf("Failed to prepare SMS login: %v", err),
-		})
+		respondError(c, apperr.Wrap(err, apperr.Internal, "Failed to prepare SMS login"))
 		return
 	}
+	startLoginWatch(session)
 
 	c.JSON(http.StatusOK, gin.H{
 		"session_id": sessionID,
@@ -430,9 +646,7 @@ func handleSendSMSCode(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": fmt.Sprintf("Invalid request body: %v", err),
-		})
+		respondError(c, apperr.Wrap(err, apperr.InvalidRequest, "Invalid request body"))
 		return
 	}
 
@@ -441,28 +655,36 @@ func handleSendSMSCode(c *gin.Context) {
 	session, exists := sessionManager.GetSession(sessionID)
 	if !exists {
 		log.Printf("会话不存在: %s\n", sessionID)
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": fmt.Sprintf("Session '%s' not found", sessionID),
-		})
+		respondError(c, apperr.New(apperr.NotFound, "Session '%s' not found", sessionID))
 		return
 	}
 
 	if session.Module.Name() != moduleName {
 		log.Printf("模块不匹配: session.module=%s, requested_module=%s\n", session.Module.Name(), moduleName)
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": fmt.Sprintf("Session does not belong to module '%s'", moduleName),
-		})
+		respondError(c, apperr.New(apperr.InvalidRequest, "Session does not belong to module '%s'", moduleName))
 		return
 	}
 
-	err := session.Module.SendSMSCode(session, req.PhoneNumber)
+	smsModule, err := smsLogin(session.Module)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	if err := validateInput(session.Module, "phone_number", req.PhoneNumber); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	err = runModule(c, session, func(session *modules.Session) error {
+		return smsModule.SendSMSCode(session, req.PhoneNumber)
+	})
 	if err != nil {
 		log.Printf("发送验证码失败: %v\n", err)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": fmt.Sprintf("Failed to send SMS code: %v", err),
-		})
+		respondError(c, apperr.Wrap(err, apperr.Internal, "Failed to send SMS code"))
 		return
 	}
+	session.Events.Publish(modules.Event{Type: modules.EventSMSSent})
 
 	c.JSON(http.StatusOK, gin.H{
 		"session_id":   sessionID,
@@ -483,9 +705,7 @@ func handleVerifySMSCode(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": fmt.Sprintf("Invalid request body: %v", err),
-		})
+		respondError(c, apperr.Wrap(err, apperr.InvalidRequest, "Invalid request body"))
 		return
 	}
 
@@ -494,26 +714,33 @@ func handleVerifySMSCode(c *gin.Context) {
 	session, exists := sessionManager.GetSession(sessionID)
 	if !exists {
 		log.Printf("会话不存在: %s\n", sessionID)
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": fmt.Sprintf("Session '%s' not found", sessionID),
-		})
+		respondError(c, apperr.New(apperr.NotFound, "Session '%s' not found", sessionID))
 		return
 	}
 
 	if session.Module.Name() != moduleName {
 		log.Printf("模块不匹配: session.module=%s, requested_module=%s\n", session.Module.Name(), moduleName)
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": fmt.Sprintf("Session does not belong to module '%s'", moduleName),
-		})
+		respondError(c, apperr.New(apperr.InvalidRequest, "Session does not belong to module '%s'", moduleName))
+		return
+	}
+
+	smsModule, err := smsLogin(session.Module)
+	if err != nil {
+		respondError(c, err)
 		return
 	}
 
-	err := session.Module.VerifySMSCode(session, req.SMSCode)
+	if err := validateInput(session.Module, "sms_code", req.SMSCode); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	err = runModule(c, session, func(session *modules.Session) error {
+		return smsModule.VerifySMSCode(session, req.SMSCode)
+	})
 	if err != nil {
 		log.Printf("验证验证码失败: %v\n", err)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": fmt.Sprintf("Failed to verify SMS code: %v", err),
-		})
+		respondError(c, apperr.Wrap(err, apperr.Internal, "Failed to verify SMS code"))
 		return
 	}
 
@@ -530,14 +757,50 @@ func startServer(cfg Config) error {
 	// 存储全局配置
 	config = cfg
 
-	// 初始化模块注册表和会话管理器
-	initModuleRegistry()
-	initSessionManager()
+	// 初始化模块注册表
+	if err := initModuleRegistry(config.ModulesDir); err != nil {
+		return err
+	}
 
 	// 初始化浏览器
 	initBrowser(config.Headless)
 	defer closeBrowser()
 
+	// 初始化会话管理器，无痕模式下共享上面的浏览器
+	if err := initSessionManager(config.SessionMode); err != nil {
+		return err
+	}
+
+	// 初始化页面池
+	if err := initPagePool(config.PoolMin, config.PoolMax); err != nil {
+		return err
+	}
+
+	// 初始化并发限制
+	l, err := limiter.New(config.MaxConcurrency, config.MaxQueue)
+	if err != nil {
+		return err
+	}
+	fetchLimiter = l
+	fmt.Printf("Fetch concurrency: %d, queue: %d\n", config.MaxConcurrency, config.MaxQueue)
+
+	// 初始化站点访问限制
+	if err := initHostLimiter(config.HostRule, config.HostRulesFile); err != nil {
+		return err
+	}
+
+	// 初始化异步任务管理器，同时执行的任务数和等待队列长度与 /fetch 相同
+	m, err := jobs.NewManager(config.MaxConcurrency, config.MaxQueue, config.JobRetention)
+	if err != nil {
+		return err
+	}
+	jobManager = m
+	fmt.Printf("Job retention: %v\n", config.JobRetention)
+
+	// 初始化登录回调
+	webhookSender = webhook.NewSender(config.WebhookSecret)
+	fmt.Printf("Login webhooks enabled: %v\n", config.WebhookSecret != "")
+
 	// 设置 Gin 模式
 	if !config.Debug {
 		gin.SetMode(gin.ReleaseMode)
@@ -563,19 +826,40 @@ func startServer(cfg Config) error {
 	// 只在调试模式下启用 Gin 的日志中间件
 	if config.Debug {
 		r.Use(gin.Logger())
-		r.Use(gin.Recovery())
-	} else {
-		// 在非调试模式下，只使用基本的恢复中间件
-		r.Use(gin.Recovery())
 	}
 
-	// 设置路由 - path 参数指定返回类型（text 或 html）
+	// 未处理的 panic 也按错误码返回，而不是空的 500
+	r.Use(gin.CustomRecovery(func(c *gin.Context, recovered interface{}) {
+		err, ok := recovered.(error)
+		if !ok {
+			err = fmt.Errorf("%v", recovered)
+		}
+		respondError(c, apperr.Wrap(err, apperr.Internal, "Unexpected error"))
+	}))
+
+	// 设置路由 - path 参数指定返回类型（text、html、markdown 或 json）
+	// POST 请求通过 JSON 请求体传递参数，适合携带结构化提取规则
 	r.GET("/fetch/:type", handleRequest)
+	r.POST("/fetch/:type", handleRequest)
+
+	// 批量提取，共享并发限制
+	r.POST("/fetch/batch", handleBatchRequest)
+
+	// 异步提取任务
+	r.POST("/jobs", handleCreateJob)
+	r.GET("/jobs", handleListJobs)
+	r.GET("/jobs/:id", handleGetJob)
+	r.DELETE("/jobs/:id", handleCancelJob)
 
 	// 新增模块化登录相关路由
 	// 创建会话
 	r.POST("/api/:module/session", handleCreateSession)
 
+	// 查看和删除会话
+	r.GET("/api/sessions", handleListSessions)
+	r.GET("/api/sessions/:id", handleInspectSession)
+	r.DELETE("/api/:module/:session_id", handleDeleteSession)
+
 	// 获取登录二维码
 	r.GET("/api/:module/:session_id/login_img", handleGetLoginQRCode)
 
@@ -588,12 +872,35 @@ func startServer(cfg Config) error {
 	// 验证短信验证码
 	r.POST("/api/:module/:session_id/verify_sms", handleVerifySMSCode)
 
+	// 账号密码登录
+	r.POST("/api/:module/:session_id/password_login", handlePasswordLogin)
+
+	// 提交二次验证码
+	r.POST("/api/:module/:session_id/second_factor", handleSubmitSecondFactor)
+
 	// 检查登录状态
 	r.GET("/api/:module/:session_id/check_login", handleCheckLogin)
 
 	// 获取登录后的cookies
 	r.GET("/api/:module/:session_id/get_cookies", handleGetCookies)
 
+	// 获取二维码解码后的内容
+	r.GET("/api/:module/:session_id/login_qr", handleGetLoginQRPayload)
+
+	// 当前二维码的版本和有效期
+	r.GET("/api/:module/:session_id/qr_status", handleQRStatus)
+
+	// 登录状态事件流 (SSE)
+	r.GET("/api/:module/:session_id/events", handleLoginEvents)
+
+	// 模块及其支持的登录方式
+	r.GET("/api/modules", handleListModules)
+	r.GET("/api/modules/:name", handleGetModule)
+
+	// 命名凭证管理
+	r.GET("/api/credentials", handleListCredentials)
+	r.DELETE("/api/credentials/:name", handleDeleteCredential)
+
 	// 添加健康检查接口
 	r.GET("/health", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{
@@ -601,6 +908,9 @@ func startServer(cfg Config) error {
 			"browser":  "connected",
 			"headless": config.Headless,
 			"port":     config.Port,
+			"pool":     pagePool.Stats(),
+			"limiter":  fetchLimiter.Stats(),
+			"jobs":     jobManager.Stats(),
 		})
 	})
 
@@ -611,13 +921,36 @@ func startServer(cfg Config) error {
 			"version": "1.0.0",
 			"usage": map[string]interface{}{
 				"endpoint":        "/fetch/{type}",
-				"types":           []string{"text", "html"},
+				"types":           []string{"text", "html", "markdown", "json", "screenshot", "pdf"},
 				"required_params": []string{"url"},
-				"optional_params": []string{"css_path", "click_css_path"},
+				"optional_params": []string{
+					"css_path", "click_css_path", "actions", "extract", "schema",
+					"session_id", "credential",
+					"cookies", "header", "user_agent", "accept_language", "referer",
+					"format", "quality", "full_page",
+					"paper", "landscape", "print_background", "margin",
+					"wait_until", "idle_ms", "wait_for", "wait_gone", "wait_js", "timeout",
+				},
 				"examples": []string{
 					"/fetch/text?url=https://example.com",
 					"/fetch/html?url=https://example.com&css_path=.content",
+					"/fetch/markdown?url=https://example.com&css_path=article",
+					"/fetch/markdown?url=https://example.com&extract=article",
+					"/fetch/screenshot?url=https://example.com&full_page=true&format=jpeg",
+					"/fetch/pdf?url=https://example.com&paper=A4&margin=1cm",
 					"/fetch/text?url=https://example.com&click_css_path=.load-more&css_path=.result",
+					"/fetch/html?url=https://example.com&wait_until=idle&wait_for=.result&timeout=20",
+				},
+				"batch": map[string]interface{}{
+					"endpoint": "POST /fetch/batch",
+					"body":     `{"items": [{"url": "https://example.com", "type": "text"}, ...]}`,
+					"max_size": maxBatchSize,
+				},
+				"jobs": map[string]interface{}{
+					"submit": "POST /jobs",
+					"status": "GET /jobs/{id}",
+					"cancel": "DELETE /jobs/{id}",
+					"body":   `{"url": "https://example.com", "type": "text"}`,
 				},
 			},
 			"modules": moduleRegistry.List(),
@@ -625,6 +958,9 @@ func startServer(cfg Config) error {
 				"port":     config.Port,
 				"headless": config.Headless,
 				"debug":    config.Debug,
+				"timeout":  config.Timeout.String(),
+
+				"session_mode": sessionManager.Mode(),
 			},
 		})
 	})
@@ -633,10 +969,16 @@ func startServer(cfg Config) error {
 	fmt.Printf("Starting Rod Browser Web Service on port %s...\n", config.Port)
 	fmt.Printf("Headless mode: %v\n", config.Headless)
 	fmt.Printf("Debug mode: %v\n", config.Debug)
+	fmt.Printf("Default timeout: %v\n", config.Timeout)
 	fmt.Println("\nAPI Examples:")
 	fmt.Printf("GET http://localhost:%s/fetch/text?url=https://example.com\n", config.Port)
 	fmt.Printf("GET http://localhost:%s/fetch/html?url=https://example.com&css_path=.content\n", config.Port)
+	fmt.Printf("GET http://localhost:%s/fetch/markdown?url=https://example.com&css_path=article\n", config.Port)
+	fmt.Printf("GET http://localhost:%s/fetch/screenshot?url=https://example.com&full_page=true\n", config.Port)
+	fmt.Printf("GET http://localhost:%s/fetch/pdf?url=https://example.com&paper=A4\n", config.Port)
 	fmt.Printf("GET http://localhost:%s/fetch/text?url=https://example.com&click_css_path=.load-more&css_path=.result\n", config.Port)
+	fmt.Printf("POST http://localhost:%s/fetch/batch\n", config.Port)
+	fmt.Printf("POST http://localhost:%s/jobs\n", config.Port)
 	fmt.Printf("POST http://localhost:%s/api/baidu/session\n", config.Port)
 	fmt.Printf("GET http://localhost:%s/api/baidu/{session_id}/login_img\n", config.Port)
 	fmt.Printf("GET http://localhost:%s/api/baidu/{session_id}/check_login\n", config.Port)
@@ -682,12 +1024,106 @@ func main() {
 				Usage:   "启用调试模式",
 				EnvVars: []string{"TEXTSURF_DEBUG"},
 			},
+			&cli.DurationFlag{
+				Name:    "timeout",
+				Aliases: []string{"t"},
+				Value:   60 * time.Second,
+				Usage:   "请求默认超时时间，0 表示不限制",
+				EnvVars: []string{"TEXTSURF_TIMEOUT"},
+			},
+			&cli.IntFlag{
+				Name:    "pool-min",
+				Value:   2,
+				Usage:   "页面池预热的页面数",
+				EnvVars: []string{"TEXTSURF_POOL_MIN"},
+			},
+			&cli.IntFlag{
+				Name:    "pool-max",
+				Value:   10,
+				Usage:   "页面池最大页面数，同时进行的 /fetch 请求超过该数量时排队等待",
+				EnvVars: []string{"TEXTSURF_POOL_MAX"},
+			},
+			&cli.StringFlag{
+				Name:    "session-mode",
+				Value:   sessions.ModeIncognito,
+				Usage:   "登录会话的浏览器模式：incognito 共享浏览器的无痕上下文，process 每个会话独立的浏览器进程",
+				EnvVars: []string{"TEXTSURF_SESSION_MODE"},
+			},
+			&cli.IntFlag{
+				Name:    "max-concurrency",
+				Value:   10,
+				Usage:   "同时执行的 /fetch 请求数",
+				EnvVars: []string{"TEXTSURF_MAX_CONCURRENCY"},
+			},
+			&cli.IntFlag{
+				Name:    "max-queue",
+				Value:   100,
+				Usage:   "/fetch 和异步任务的等待队列长度，队列已满时返回 429",
+				EnvVars: []string{"TEXTSURF_MAX_QUEUE"},
+			},
+			&cli.Float64Flag{
+				Name:    "host-rps",
+				Value:   0,
+				Usage:   "每个站点每秒最多发起的导航次数，0 表示不限制",
+				EnvVars: []string{"TEXTSURF_HOST_RPS"},
+			},
+			&cli.IntFlag{
+				Name:    "host-concurrency",
+				Value:   0,
+				Usage:   "每个站点同时打开的标签页数，0 表示不限制",
+				EnvVars: []string{"TEXTSURF_HOST_CONCURRENCY"},
+			},
+			&cli.DurationFlag{
+				Name:    "host-delay",
+				Value:   0,
+				Usage:   "同一站点两次导航之间的最小间隔",
+				EnvVars: []string{"TEXTSURF_HOST_DELAY"},
+			},
+			&cli.StringFlag{
+				Name:    "host-rules",
+				Usage:   "按域名覆盖访问限制的规则文件 (YAML 或 JSON)",
+				EnvVars: []string{"TEXTSURF_HOST_RULES"},
+			},
+			&cli.DurationFlag{
+				Name:    "job-retention",
+				Value:   time.Hour,
+				Usage:   "异步任务结束后结果的保留时间",
+				EnvVars: []string{"TEXTSURF_JOB_RETENTION"},
+			},
+			&cli.StringFlag{
+				Name:    "webhook-secret",
+				Usage:   "登录回调的 HMAC-SHA256 签名密钥，未设置时不能使用 callback_url",
+				EnvVars: []string{"TEXTSURF_WEBHOOK_SECRET"},
+			},
+			&cli.StringFlag{
+				Name:    "modules-dir",
+				Usage:   "声明式模块定义文件目录 (YAML 或 JSON)，启动时加载",
+				EnvVars: []string{"TEXTSURF_MODULES_DIR"},
+			},
 		},
 		Action: func(ctx *cli.Context) error {
 			config := Config{
 				Port:     ctx.String("port"),
 				Headless: ctx.Bool("headless"),
 				Debug:    ctx.Bool("debug"),
+				Timeout:  ctx.Duration("timeout"),
+				PoolMin:  ctx.Int("pool-min"),
+				PoolMax:  ctx.Int("pool-max"),
+
+				SessionMode: ctx.String("session-mode"),
+
+				MaxConcurrency: ctx.Int("max-concurrency"),
+				MaxQueue:       ctx.Int("max-queue"),
+
+				HostRule: limiter.HostRule{
+					RPS:           ctx.Float64("host-rps"),
+					MaxConcurrent: ctx.Int("host-concurrency"),
+					MinDelay:      ctx.Duration("host-delay"),
+				},
+				HostRulesFile: ctx.String("host-rules"),
+				JobRetention:  ctx.Duration("job-retention"),
+				WebhookSecret: ctx.String("webhook-secret"),
+				ModulesDir:    ctx.String("modules-dir"),
 			}
 
 			return startServer(config)
@@ -1,9 +1,9 @@
 package baichuanweb
 
 import (
-	"fmt"
 	"log"
-	"sync"
+	"strings"
+	"textsurf/apperr"
 	"textsurf/modules"
 	"time"
 
@@ -11,9 +11,15 @@ import (
 	"github.com/go-rod/rod/lib/proto"
 )
 
-type BaichuanwebModule struct {
-	pageMutex sync.Mutex
-}
+// 模块支持的登录方式
+var (
+	_ modules.SMSLogin = (*BaichuanwebModule)(nil)
+)
+
+// 短信登录页面
+const loginURL = "https://www.baichuanweb.com/portal/login"
+
+type BaichuanwebModule struct{}
 
 func NewBaichuanwebModule() modules.Module {
 	return &BaichuanwebModule{}
@@ -23,37 +29,56 @@ func (m *BaichuanwebModule) Name() string {
 	return "baichuanweb"
 }
 
-func (m *BaichuanwebModule) GetLoginQRCode(session *modules.Session) (string, error) {
-	return "", fmt.Errorf("百川网页面不支持二维码登录")
-}
-
-func (m *BaichuanwebModule) GetLoginQRCodeImage(session *modules.Session) ([]byte, error) {
-	return nil, fmt.Errorf("百川网页面不支持二维码登录")
+// Metadata 返回模块的描述信息
+func (m *BaichuanwebModule) Metadata() modules.Metadata {
+	return modules.Metadata{
+		DisplayName: "百川网",
+		Site:        "https://www.baichuanweb.com",
+		Version:     "1.0.0",
+		Inputs: []modules.Input{
+			{Name: "phone_number", Method: modules.CapabilitySMS, Label: "手机号"},
+			{Name: "sms_code", Method: modules.CapabilitySMS, Label: "短信验证码"},
+		},
+		CookieDomains: []string{"www.baichuanweb.com"},
+	}
 }
 
 func (m *BaichuanwebModule) PrepareSMSLogin(session *modules.Session) (map[string]interface{}, error) {
-	m.pageMutex.Lock()
-	defer m.pageMutex.Unlock()
+	session.LockPage()
+	defer session.UnlockPage()
 
 	log.Println("正在访问百川网登录页面...")
 
 	// 使用现有的 stealth 页面
 	page := session.Page
 	if page == nil {
-		return nil, fmt.Errorf("会话页面未初始化")
+		return nil, apperr.New(apperr.InvalidRequest, "会话页面未初始化")
 	}
 
 	// 导航到登录页面
-	page.MustNavigate("https://www.baichuanweb.com/portal/login")
+	if err := page.Navigate(loginURL); err != nil {
+		return nil, apperr.Wrap(err, apperr.NavigationFailed, "无法打开百川网登录页面")
+	}
 
 	log.Println("等待页面加载...")
-	page.MustWaitLoad()
+	if err := page.WaitLoad(); err != nil {
+		return nil, apperr.Wrap(err, apperr.NavigationFailed, "百川网登录页面加载失败")
+	}
 	time.Sleep(3 * time.Second)
 
+	if captcha, _ := modules.DetectCaptcha(page); captcha {
+		return nil, apperr.New(apperr.CaptchaDetected, "百川网登录页面要求进行人机验证")
+	}
+
+	pageInfo, err := page.Info()
+	if err != nil {
+		return nil, apperr.Wrap(err, apperr.BrowserCrashed, "无法读取页面信息")
+	}
+
 	info := map[string]interface{}{
 		"status":     "ready",
 		"login_type": "sms",
-		"url":        page.MustInfo().URL,
+		"url":        pageInfo.URL,
 	}
 
 	log.Println("短信登录页面准备完成")
@@ -61,11 +86,11 @@ func (m *BaichuanwebModule) PrepareSMSLogin(session *modules.Session) (map[strin
 }
 
 func (m *BaichuanwebModule) SendSMSCode(session *modules.Session, phoneNumber string) error {
-	m.pageMutex.Lock()
-	defer m.pageMutex.Unlock()
+	session.LockPage()
+	defer session.UnlockPage()
 
 	if session.Page == nil {
-		return fmt.Errorf("会话页面未初始化，请先准备登录页面")
+		return apperr.New(apperr.InvalidRequest, "会话页面未初始化，请先准备登录页面")
 	}
 
 	log.Printf("准备发送验证码到手机号: %s", phoneNumber)
@@ -75,48 +100,56 @@ func (m *BaichuanwebModule) SendSMSCode(session *modules.Session, phoneNumber st
 	log.Println("1. 勾选用户协议复选框...")
 	checkboxLabel, err := page.Element("label.el-checkbox")
 	if err != nil {
-		return fmt.Errorf("无法找到用户协议复选框: %v", err)
+		return apperr.Wrap(err, apperr.SelectorNotFound, "无法找到用户协议复选框")
 	}
 
-	checkboxLabel.MustClick()
+	if err := checkboxLabel.Click(proto.InputMouseButtonLeft, 1); err != nil {
+		return apperr.Wrap(err, apperr.Internal, "无法勾选用户协议")
+	}
 	time.Sleep(500 * time.Millisecond)
 	log.Println("已点击用户协议复选框")
 
 	log.Println("2. 输入手机号...")
-	phoneInput, err := page.Element("input[maxlength='11'][placeholder*='手机号']")
+	// 两种占位文本合并为一个选择器，避免第一个选择器等待到超时
+	phoneInput, err := page.Element("input[maxlength='11'][placeholder*='手机号'], input[maxlength='11'][placeholder*='输入']")
 	if err != nil {
-		phoneInput, err = page.Element("input[maxlength='11'][placeholder*='输入']")
-		if err != nil {
-			return fmt.Errorf("无法找到手机号输入框: %v", err)
-		}
+		return apperr.Wrap(err, apperr.SelectorNotFound, "无法找到手机号输入框")
 	}
 
-	phoneInput.MustInput("")
-	phoneInput.MustInput(phoneNumber)
+	if err := inputText(phoneInput, phoneNumber); err != nil {
+		return apperr.Wrap(err, apperr.Internal, "无法输入手机号")
+	}
 	time.Sleep(500 * time.Millisecond)
 	log.Printf("手机号已输入: %s", phoneNumber)
 
 	log.Println("3. 点击获取验证码按钮...")
 	sendButton, err := page.Element(".code-right")
 	if err != nil {
-		return fmt.Errorf("无法找到获取验证码按钮: %v", err)
+		return apperr.Wrap(err, apperr.SelectorNotFound, "无法找到获取验证码按钮")
 	}
 
-	sendButton.MustClick()
+	if err := sendButton.Click(proto.InputMouseButtonLeft, 1); err != nil {
+		return apperr.Wrap(err, apperr.Internal, "无法点击获取验证码按钮")
+	}
 	log.Println("已点击获取验证码按钮")
 
 	time.Sleep(2 * time.Second)
 
+	// 发送验证码前可能弹出滑块验证
+	if captcha, _ := modules.DetectCaptcha(page); captcha {
+		return apperr.New(apperr.CaptchaDetected, "发送验证码需要进行人机验证")
+	}
+
 	log.Println("验证码发送成功")
 	return nil
 }
 
 func (m *BaichuanwebModule) VerifySMSCode(session *modules.Session, smsCode string) error {
-	m.pageMutex.Lock()
-	defer m.pageMutex.Unlock()
+	session.LockPage()
+	defer session.UnlockPage()
 
 	if session.Page == nil {
-		return fmt.Errorf("会话页面未初始化，请先准备登录页面")
+		return apperr.New(apperr.InvalidRequest, "会话页面未初始化，请先准备登录页面")
 	}
 
 	log.Printf("准备验证短信验证码: %s", smsCode)
@@ -126,21 +159,24 @@ func (m *BaichuanwebModule) VerifySMSCode(session *modules.Session, smsCode stri
 	log.Println("1. 输入验证码...")
 	codeInput, err := page.Element("input[maxlength='6'][placeholder*='验证码']")
 	if err != nil {
-		return fmt.Errorf("无法找到验证码输入框: %v", err)
+		return apperr.Wrap(err, apperr.SelectorNotFound, "无法找到验证码输入框")
 	}
 
-	codeInput.MustInput("")
-	codeInput.MustInput(smsCode)
+	if err := inputText(codeInput, smsCode); err != nil {
+		return apperr.Wrap(err, apperr.Internal, "无法输入验证码")
+	}
 	time.Sleep(500 * time.Millisecond)
 	log.Printf("验证码已输入: %s", smsCode)
 
 	log.Println("2. 点击登录按钮...")
 	loginButton, err := page.Element(".login-btn")
 	if err != nil {
-		return fmt.Errorf("无法找到登录按钮: %v", err)
+		return apperr.Wrap(err, apperr.SelectorNotFound, "无法找到登录按钮")
 	}
 
-	loginButton.MustClick()
+	if err := loginButton.Click(proto.InputMouseButtonLeft, 1); err != nil {
+		return apperr.Wrap(err, apperr.Internal, "无法点击登录按钮")
+	}
 	log.Println("已点击登录按钮")
 
 	time.Sleep(3 * time.Second)
@@ -151,37 +187,37 @@ func (m *BaichuanwebModule) VerifySMSCode(session *modules.Session, smsCode stri
 
 func (m *BaichuanwebModule) CheckLogin(session *modules.Session) (bool, map[string]string, error) {
 	if session.Page == nil {
-		return false, nil, fmt.Errorf("会话页面未初始化，请先准备登录页面")
+		return false, nil, apperr.New(apperr.InvalidRequest, "会话页面未初始化，请先准备登录页面")
 	}
 
-	m.pageMutex.Lock()
-	defer m.pageMutex.Unlock()
+	session.LockPage()
+	defer session.UnlockPage()
 
-	if err := rod.Try(func() {
-		session.Page.MustInfo()
-	}); err != nil {
-		return false, nil, fmt.Errorf("浏览器连接已关闭: %v", err)
+	// 浏览器连接提前关闭时返回 browser_crashed
+	pageInfo, err := session.Page.Info()
+	if err != nil {
+		return false, nil, apperr.Wrap(err, apperr.BrowserCrashed, "浏览器连接已关闭")
 	}
 
-	currentURL := session.Page.MustInfo().URL
+	currentURL := pageInfo.URL
 	log.Printf("当前页面URL: %s", currentURL)
 
 	loginSuccess := false
 
-	if currentURL != "https://www.baichuanweb.com/portal/login" {
+	// 会话页面还是空白页或已离开站点时，不能据 URL 判断登录结果
+	if modules.OnSite(currentURL, "baichuanweb.com") && !strings.HasPrefix(currentURL, loginURL) {
 		log.Println("URL已跳转，可能登录成功")
 		loginSuccess = true
 	}
 
+	// Has 不等待元素出现
 	log.Println("检查登录成功标志...")
-	_, err := session.Page.Element(".red-color")
-	if err == nil {
+	if has, _, _ := session.Page.Has(".red-color"); has {
 		log.Println("找到退出登录按钮，登录成功")
 		loginSuccess = true
 	}
 
-	_, err = session.Page.Element(".el-button--primary")
-	if err == nil {
+	if has, _, _ := session.Page.Has(".el-button--primary"); has {
 		log.Println("找到我的工作台按钮，登录成功")
 		loginSuccess = true
 	}
@@ -199,10 +235,9 @@ func (m *BaichuanwebModule) CheckLogin(session *modules.Session) (bool, map[stri
 		if err == nil {
 			for _, btn := range closeButtons {
 				if btn != nil {
-					rod.Try(func() {
-						btn.MustClick()
+					if err := btn.Click(proto.InputMouseButtonLeft, 1); err == nil {
 						log.Println("已关闭一个横幅")
-					})
+					}
 					time.Sleep(300 * time.Millisecond)
 				}
 			}
@@ -224,11 +259,11 @@ func (m *BaichuanwebModule) CheckLogin(session *modules.Session) (bool, map[stri
 		case <-done:
 			if cookieErr != nil {
 				log.Printf("获取cookies失败: %v", cookieErr)
-				return false, nil, fmt.Errorf("获取cookies失败: %v", cookieErr)
+				return false, nil, apperr.Wrap(cookieErr, apperr.Internal, "获取cookies失败")
 			}
 		case <-time.After(10 * time.Second):
 			log.Println("获取cookies超时")
-			return false, nil, fmt.Errorf("获取cookies超时")
+			return false, nil, apperr.New(apperr.Timeout, "获取cookies超时")
 		}
 
 		cookieMap := make(map[string]string)
@@ -243,14 +278,16 @@ func (m *BaichuanwebModule) CheckLogin(session *modules.Session) (bool, map[stri
 		return true, cookieMap, nil
 	}
 
-	_, err = session.Page.Element(".error-message")
-	if err == nil {
-		return false, nil, fmt.Errorf("登录失败，请检查验证码是否正确")
+	if captcha, _ := modules.DetectCaptcha(session.Page); captcha {
+		return false, nil, apperr.New(apperr.CaptchaDetected, "登录需要进行人机验证")
+	}
+
+	if has, _, _ := session.Page.Has(".error-message"); has {
+		return false, nil, apperr.New(apperr.LoginRejected, "登录失败，请检查验证码是否正确")
 	}
 
-	_, err = session.Page.Element(".login-failed")
-	if err == nil {
-		return false, nil, fmt.Errorf("登录失败，请检查验证码是否正确")
+	if has, _, _ := session.Page.Has(".login-failed"); has {
+		return false, nil, apperr.New(apperr.LoginRejected, "登录失败，请检查验证码是否正确")
 	}
 
 	log.Println("登录状态未确定，返回未登录")
@@ -259,7 +296,15 @@ func (m *BaichuanwebModule) CheckLogin(session *modules.Session) (bool, map[stri
 
 func (m *BaichuanwebModule) Close(session *modules.Session) error {
 	if session.Browser != nil {
-		session.Browser.MustClose()
+		return session.Browser.Close()
 	}
 	return nil
 }
+
+// inputText 清空输入框后输入文本
+func inputText(el *rod.Element, text string) error {
+	if err := el.Input(""); err != nil {
+		return err
+	}
+	return el.Input(text)
+}
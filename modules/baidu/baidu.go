@@ -1,21 +1,54 @@
 package baidu
 
 import (
-	"fmt"
 	"log"
 	"strings"
-	"sync"
+	"textsurf/apperr"
 	"textsurf/modules"
 	"time"
 
 	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/proto"
 )
 
-type BaiduModule struct {
-	// 为每个模块实例添加一个互斥锁来保护页面访问
-	pageMutex sync.Mutex
+// 二维码图片元素
+const qrCodeSelector = "#TANGRAM__PSP_3__QrcodeMain > img"
+
+// 百度二维码的大致有效期
+const qrTTL = 2 * time.Minute
+
+// 二维码过期时显示的遮罩和刷新按钮
+var qrRefreshSelectors = []string{
+	".tang-pass-qrcode-refresh",
+	".Qrcode-refresh-btn",
+	".pass-qrcode-refresh",
+	"#TANGRAM__PSP_3__QrcodeMain .refresh",
 }
 
+// 账号密码登录表单的元素
+const (
+	passwordTabSelector    = "#TANGRAM__PSP_3__footerULoginBtn"
+	usernameSelector       = "#TANGRAM__PSP_3__userName"
+	passwordSelector       = "#TANGRAM__PSP_3__password"
+	rememberSelector       = "#TANGRAM__PSP_3__memberPass"
+	submitSelector         = "#TANGRAM__PSP_3__submit"
+	loginErrorSelector     = "#TANGRAM__PSP_3__error"
+	verifyCodeSelector     = "#TANGRAM__PSP_3__verifyCode"
+	sendMobileCodeSelector = "[id$='__button_send_mobile']"
+	secondFactorSelector   = "[id$='__input_vcode']"
+	secondFactorSubmit     = "[id$='__button_submit']"
+)
+
+// 模块支持的登录方式
+var (
+	_ modules.QRLogin       = (*BaiduModule)(nil)
+	_ modules.QRRefresher   = (*BaiduModule)(nil)
+	_ modules.PasswordLogin = (*BaiduModule)(nil)
+)
+
+// BaiduModule 的页面操作使用会话自己的锁，同一模块的会话互不阻塞
+type BaiduModule struct{}
+
 func NewBaiduModule() modules.Module {
 	return &BaiduModule{}
 }
@@ -24,44 +57,176 @@ func (m *BaiduModule) Name() string {
 	return "baidu"
 }
 
+// Metadata 返回模块的描述信息
+func (m *BaiduModule) Metadata() modules.Metadata {
+	return modules.Metadata{
+		DisplayName: "百度",
+		Site:        "https://passport.baidu.com",
+		Version:     "1.1.0",
+		Inputs: []modules.Input{
+			{Name: "username", Method: modules.CapabilityPassword, Label: "手机号/用户名/邮箱"},
+			{Name: "password", Method: modules.CapabilityPassword, Label: "密码", Secret: true},
+			{Name: "code", Method: modules.CapabilityPassword, Label: "安全验证短信验证码", Pattern: `^\d{6}$`},
+		},
+		QRLifetime:    int(qrTTL.Seconds()),
+		CookieDomains: []string{".baidu.com", "passport.baidu.com"},
+	}
+}
+
 func (m *BaiduModule) GetLoginQRCode(session *modules.Session) (string, error) {
+	// 当前二维码仍然有效时直接返回
+	if qr, ok := session.QR.Current(); ok && !qr.Expired() && qr.URL != "" {
+		return qr.URL, nil
+	}
+
+	qr, err := m.loadQRCode(session)
+	if err != nil {
+		return "", err
+	}
+	return qr.URL, nil
+}
+
+// GetLoginQRCodeImage 获取登录二维码图片内容
+// 当前二维码仍然有效时直接返回，不会重新打开登录页面
+func (m *BaiduModule) GetLoginQRCodeImage(session *modules.Session) ([]byte, error) {
+	if qr, ok := session.QR.Current(); ok && !qr.Expired() && qr.Image != nil {
+		return qr.Image, nil
+	}
+
+	qr, err := m.loadQRCode(session)
+	if err != nil {
+		return nil, err
+	}
+	return qr.Image, nil
+}
+
+// RefreshQRCode 检查页面上的二维码是否过期，过期时点击刷新按钮，没有刷新按钮时重新加载登录页面
+// 站点自动更换了二维码时同步新的二维码
+func (m *BaiduModule) RefreshQRCode(session *modules.Session) (bool, error) {
+	current, ok := session.QR.Current()
+	if !ok || session.Page == nil {
+		return false, nil
+	}
+
+	session.LockPage()
+	defer session.UnlockPage()
+
+	page := session.Page
+	expired, err := modules.DetectQRExpired(page, qrRefreshSelectors)
+	if err != nil {
+		return false, apperr.Wrap(err, apperr.Internal, "检查二维码状态失败")
+	}
+
+	if !expired && !current.Expired() {
+		// 站点自动刷新二维码后图片地址会变化，Has 不等待元素出现
+		if has, _, _ := page.Has(qrCodeSelector); !has {
+			return false, nil
+		}
+		src, err := qrCodeSrc(page)
+		if err != nil || src == current.URL {
+			return false, nil
+		}
+		log.Println("百度二维码已自动更新")
+		if _, err := captureQRCode(session, page); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+
+	log.Println("百度二维码已过期，正在刷新...")
+	if !clickRefresh(page) {
+		log.Println("未找到刷新按钮，重新加载登录页面...")
+		if err := page.Reload(); err != nil {
+			return false, apperr.Wrap(err, apperr.NavigationFailed, "重新加载百度登录页面失败")
+		}
+		if err := page.WaitLoad(); err != nil {
+			return false, apperr.Wrap(err, apperr.NavigationFailed, "百度登录页面加载失败")
+		}
+		if err := switchToQRCode(page); err != nil {
+			return false, err
+		}
+	}
+	time.Sleep(2 * time.Second)
+
+	if _, err := captureQRCode(session, page); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// loadQRCode 打开登录页面，切换到二维码登录并记录二维码
+// 与登录监听的 RefreshQRCode 共用页面和 session.Data，需要持有锁
+func (m *BaiduModule) loadQRCode(session *modules.Session) (modules.QRCode, error) {
+	session.LockPage()
+	defer session.UnlockPage()
+
 	// 访问百度登录页面
 	log.Println("正在访问百度登录页面...")
-	page := session.Browser.MustPage("https://passport.baidu.com/v2/?login")
-	session.Page = page
-
-	// 等待页面加载
-	log.Println("等待页面加载...")
-	page.MustWaitLoad()
+	page, err := openLoginPage(session)
+	if err != nil {
+		return modules.QRCode{}, err
+	}
 	time.Sleep(2 * time.Second)
 
-	// 尝试切换到二维码登录 (如果默认不是二维码登录)
-	// 使用 TryElement 来避免阻塞
-	log.Println("检查是否有二维码登录选项...")
-	if loginTypeSwitch, err := page.Element("a[data-type='qrcode']"); err == nil {
-		log.Println("找到二维码登录选项，点击切换...")
-		loginTypeSwitch.MustClick()
-		time.Sleep(1 * time.Second)
+	if err := switchToQRCode(page); err != nil {
+		return modules.QRCode{}, err
 	}
 
 	// 等待二维码加载
 	log.Println("等待二维码加载...")
-	page.MustWaitLoad()
+	if err := page.WaitLoad(); err != nil {
+		return modules.QRCode{}, apperr.Wrap(err, apperr.NavigationFailed, "二维码加载失败")
+	}
 	time.Sleep(3 * time.Second)
 
-	// 查找二维码图片元素，使用你提供的精确选择器
-	log.Println("查找二维码图片元素...")
-	qrElement, err := page.Element("#TANGRAM__PSP_3__QrcodeMain > img")
+	return captureQRCode(session, page)
+}
+
+// switchToQRCode 切换到二维码登录 (如果默认不是二维码登录)
+func switchToQRCode(page *rod.Page) error {
+	// 使用 Has 来避免阻塞
+	log.Println("检查是否有二维码登录选项...")
+	if has, loginTypeSwitch, _ := page.Has("a[data-type='qrcode']"); has {
+		log.Println("找到二维码登录选项，点击切换...")
+		if err := loginTypeSwitch.Click(proto.InputMouseButtonLeft, 1); err != nil {
+			return apperr.Wrap(err, apperr.Internal, "无法切换到二维码登录")
+		}
+		time.Sleep(1 * time.Second)
+	}
+	return nil
+}
+
+// clickRefresh 点击二维码的刷新按钮，没有可见的刷新按钮时返回 false
+func clickRefresh(page *rod.Page) bool {
+	for _, selector := range qrRefreshSelectors {
+		has, el, _ := page.Has(selector)
+		if !has {
+			continue
+		}
+		if visible, _ := el.Visible(); !visible {
+			continue
+		}
+		if err := el.Click(proto.InputMouseButtonLeft, 1); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// qrCodeSrc 读取二维码图片的 src，相对路径补全为绝对路径
+func qrCodeSrc(page *rod.Page) (string, error) {
+	qrElement, err := page.Element(qrCodeSelector)
 	if err != nil {
 		log.Println("无法找到二维码元素: ", err)
-		return "", fmt.Errorf("无法找到二维码元素: %v", err)
+		return "", apperr.Wrap(err, apperr.SelectorNotFound, "无法找到二维码元素")
 	}
 
-	log.Println("找到二维码元素，获取src属性...")
-	// 获取二维码图片的 src
 	qrSrc, err := qrElement.Attribute("src")
 	if err != nil {
-		return "", fmt.Errorf("无法获取二维码图片src: %v", err)
+		return "", apperr.Wrap(err, apperr.Internal, "无法获取二维码图片src")
+	}
+	if qrSrc == nil || *qrSrc == "" {
+		return "", apperr.New(apperr.SelectorNotFound, "二维码图片没有src属性")
 	}
 
 	// 如果是相对路径，补全为绝对路径
@@ -69,75 +234,55 @@ func (m *BaiduModule) GetLoginQRCode(session *modules.Session) (string, error) {
 	if qrURL[0] == '/' {
 		qrURL = "https://passport.baidu.com" + qrURL
 	}
-
-	// 保存二维码URL到会话数据中供后续检查使用
-	session.Data["qrURL"] = qrURL
-
-	log.Printf("成功获取二维码URL: %s\n", qrURL)
 	return qrURL, nil
 }
 
-// GetLoginQRCodeImage 获取登录二维码图片内容
-func (m *BaiduModule) GetLoginQRCodeImage(session *modules.Session) ([]byte, error) {
-	// 访问百度登录页面
-	log.Println("正在访问百度登录页面...")
-	page := session.Browser.MustPage("https://passport.baidu.com/v2/?login")
-	session.Page = page
-
-	// 等待页面加载
-	log.Println("等待页面加载...")
-	page.MustWaitLoad()
-
+// captureQRCode 读取页面上的二维码地址和图片内容，记录为会话的新二维码
+func captureQRCode(session *modules.Session, page *rod.Page) (modules.QRCode, error) {
 	// 查找二维码图片元素，使用你提供的精确选择器
 	log.Println("查找二维码图片元素...")
-	qrElement, err := page.Element("#TANGRAM__PSP_3__QrcodeMain > img")
+	qrURL, err := qrCodeSrc(page)
 	if err != nil {
-		log.Println("无法找到二维码元素: ", err)
-		return nil, fmt.Errorf("无法找到二维码元素: %v", err)
+		return modules.QRCode{}, err
+	}
+
+	qrElement, err := page.Element(qrCodeSelector)
+	if err != nil {
+		return modules.QRCode{}, apperr.Wrap(err, apperr.SelectorNotFound, "无法找到二维码元素")
 	}
 
 	log.Println("找到二维码元素，获取图片内容...")
-	// 获取二维码图片内容
 	imgBytes, err := qrElement.Resource()
 	if err != nil {
-		return nil, fmt.Errorf("无法获取二维码图片内容: %v", err)
+		return modules.QRCode{}, apperr.Wrap(err, apperr.Internal, "无法获取二维码图片内容")
 	}
 
-	log.Println("成功获取二维码图片内容")
-	return imgBytes, nil
-}
-
-func (m *BaiduModule) PrepareSMSLogin(session *modules.Session) (map[string]interface{}, error) {
-	return nil, fmt.Errorf("百度模块不支持短信登录")
-}
-
-func (m *BaiduModule) SendSMSCode(session *modules.Session, phoneNumber string) error {
-	return fmt.Errorf("百度模块不支持短信登录")
-}
+	// 保存二维码URL到会话数据中供后续检查使用
+	session.Data["qrURL"] = qrURL
 
-func (m *BaiduModule) VerifySMSCode(session *modules.Session, smsCode string) error {
-	return fmt.Errorf("百度模块不支持短信登录")
+	qr := modules.UpdateQRCode(session, imgBytes, qrURL, qrTTL)
+	log.Printf("成功获取二维码 (版本 %d): %s\n", qr.Version, qrURL)
+	return qr, nil
 }
 
 func (m *BaiduModule) CheckLogin(session *modules.Session) (bool, map[string]string, error) {
 	// 检查页面是否已初始化
 	if session.Page == nil {
-		return false, nil, fmt.Errorf("会话页面未初始化，请先获取登录二维码")
+		return false, nil, apperr.New(apperr.InvalidRequest, "会话页面未初始化，请先获取登录二维码")
 	}
 
 	// 使用互斥锁保护对页面的访问，防止并发访问导致的竞态条件
-	m.pageMutex.Lock()
-	defer m.pageMutex.Unlock()
+	session.LockPage()
+	defer session.UnlockPage()
 
-	// 防止浏览器连接提前关闭导致的 panic
-	if err := rod.Try(func() {
-		session.Page.MustInfo()
-	}); err != nil {
-		return false, nil, fmt.Errorf("浏览器连接已关闭: %v", err)
+	// 浏览器连接提前关闭时返回 browser_crashed
+	info, err := session.Page.Info()
+	if err != nil {
+		return false, nil, apperr.Wrap(err, apperr.BrowserCrashed, "浏览器连接已关闭")
 	}
 
 	// 检查页面URL
-	url := session.Page.MustInfo().URL
+	url := info.URL
 	log.Printf("当前页面URL: %s", url)
 
 	// 百度登录成功后通常会跳转到个人中心页面
@@ -148,7 +293,7 @@ func (m *BaiduModule) CheckLogin(session *modules.Session) (bool, map[string]str
 		// 获取cookies
 		cookies, err := session.Page.Cookies([]string{})
 		if err != nil {
-			return false, nil, fmt.Errorf("获取cookies失败: %v", err)
+			return false, nil, apperr.Wrap(err, apperr.Internal, "获取cookies失败")
 		}
 
 		// 转换为字符串map
@@ -160,13 +305,12 @@ func (m *BaiduModule) CheckLogin(session *modules.Session) (bool, map[string]str
 		return true, cookieMap, nil
 	}
 
-	// 检查是否有登录成功的标志元素 - 用户名
-	_, err := session.Page.Element(".mod-center-username")
-	if err == nil {
+	// 检查是否有登录成功的标志元素 - 用户名（Has 不等待元素出现）
+	if has, _, _ := session.Page.Has(".mod-center-username"); has {
 		// 获取cookies
 		cookies, err := session.Page.Cookies([]string{})
 		if err != nil {
-			return false, nil, fmt.Errorf("获取cookies失败: %v", err)
+			return false, nil, apperr.Wrap(err, apperr.Internal, "获取cookies失败")
 		}
 
 		// 转换为字符串map
@@ -179,12 +323,11 @@ func (m *BaiduModule) CheckLogin(session *modules.Session) (bool, map[string]str
 	}
 
 	// 检查是否有登录成功的标志元素 - 用户头像
-	_, err = session.Page.Element(".imgimgClas")
-	if err == nil {
+	if has, _, _ := session.Page.Has(".imgimgClas"); has {
 		// 获取cookies
 		cookies, err := session.Page.Cookies([]string{})
 		if err != nil {
-			return false, nil, fmt.Errorf("获取cookies失败: %v", err)
+			return false, nil, apperr.Wrap(err, apperr.Internal, "获取cookies失败")
 		}
 
 		// 转换为字符串map
@@ -196,19 +339,172 @@ func (m *BaiduModule) CheckLogin(session *modules.Session) (bool, map[string]str
 		return true, cookieMap, nil
 	}
 
+	// 检查是否需要人机验证
+	if captcha, _ := modules.DetectCaptcha(session.Page); captcha {
+		return false, nil, apperr.New(apperr.CaptchaDetected, "百度要求进行安全验证")
+	}
+
 	// 检查是否有登录失败的提示
-	_, err = session.Page.Element(".pass-state-error")
-	if err == nil {
-		return false, nil, fmt.Errorf("登录失败，请重新尝试")
+	if has, _, _ := session.Page.Has(".pass-state-error"); has {
+		return false, nil, apperr.New(apperr.LoginRejected, "登录失败，请重新尝试")
 	}
 
 	// 如果都没有明确结果，返回未登录状态
 	return false, nil, nil
 }
 
+// LoginWithPassword 切换到账号登录，输入账号密码后提交
+// 百度对异地或新设备登录会要求短信安全验证，此时自动发送验证码并返回 second_factor_required
+func (m *BaiduModule) LoginWithPassword(session *modules.Session, credentials modules.PasswordCredentials) (modules.PasswordResult, error) {
+	session.LockPage()
+	defer session.UnlockPage()
+
+	log.Println("正在访问百度登录页面...")
+	page, err := openLoginPage(session)
+	if err != nil {
+		return modules.PasswordResult{}, err
+	}
+	time.Sleep(2 * time.Second)
+
+	// 默认显示二维码登录时切换到账号登录
+	if has, tab, _ := page.Has(passwordTabSelector); has {
+		if visible, _ := tab.Visible(); visible {
+			log.Println("切换到账号登录...")
+			if err := tab.Click(proto.InputMouseButtonLeft, 1); err != nil {
+				return modules.PasswordResult{}, apperr.Wrap(err, apperr.Internal, "无法切换到账号登录")
+			}
+			time.Sleep(time.Second)
+		}
+	}
+
+	if err := inputText(page, usernameSelector, credentials.Username); err != nil {
+		return modules.PasswordResult{}, apperr.Wrap(err, apperr.SelectorNotFound, "无法输入百度账号")
+	}
+	if err := inputText(page, passwordSelector, credentials.Password); err != nil {
+		return modules.PasswordResult{}, apperr.Wrap(err, apperr.SelectorNotFound, "无法输入百度密码")
+	}
+	if err := setRemember(page, credentials.Remember); err != nil {
+		return modules.PasswordResult{}, apperr.Wrap(err, apperr.Internal, "无法设置下次自动登录")
+	}
+
+	log.Println("提交百度账号密码...")
+	submit, err := page.Element(submitSelector)
+	if err != nil {
+		return modules.PasswordResult{}, apperr.Wrap(err, apperr.SelectorNotFound, "无法找到登录按钮")
+	}
+	if err := submit.Click(proto.InputMouseButtonLeft, 1); err != nil {
+		return modules.PasswordResult{}, apperr.Wrap(err, apperr.Internal, "无法点击登录按钮")
+	}
+	time.Sleep(3 * time.Second)
+
+	// 图片验证码或旋转验证
+	if captcha, _ := modules.DetectCaptcha(page); captcha {
+		return modules.PasswordResult{State: modules.PasswordCaptchaRequired, Message: "百度要求完成安全验证"}, nil
+	}
+	if has, el, _ := page.Has(verifyCodeSelector); has {
+		if visible, _ := el.Visible(); visible {
+			return modules.PasswordResult{State: modules.PasswordCaptchaRequired, Message: "百度要求输入图片验证码"}, nil
+		}
+	}
+
+	// 账号或密码错误
+	if has, el, _ := page.Has(loginErrorSelector); has {
+		if text, _ := el.Text(); strings.TrimSpace(text) != "" {
+			return modules.PasswordResult{}, apperr.New(apperr.LoginRejected, "百度登录失败: %s", strings.TrimSpace(text))
+		}
+	}
+
+	if required, _ := modules.DetectSecondFactor(page, []string{sendMobileCodeSelector, secondFactorSelector}); required {
+		log.Println("百度要求短信安全验证，发送验证码...")
+		if has, button, _ := page.Has(sendMobileCodeSelector); has {
+			if err := button.Click(proto.InputMouseButtonLeft, 1); err != nil {
+				return modules.PasswordResult{}, apperr.Wrap(err, apperr.Internal, "无法发送安全验证短信")
+			}
+		}
+		return modules.PasswordResult{State: modules.PasswordSecondFactorRequired, Message: "验证码已发送到账号绑定的手机"}, nil
+	}
+
+	return modules.PasswordResult{State: modules.PasswordSubmitted}, nil
+}
+
+// SubmitSecondFactor 输入安全验证短信验证码并提交
+func (m *BaiduModule) SubmitSecondFactor(session *modules.Session, code string) error {
+	if session.Page == nil {
+		return apperr.New(apperr.InvalidRequest, "会话页面未初始化，请先提交账号密码")
+	}
+
+	session.LockPage()
+	defer session.UnlockPage()
+
+	page := session.Page
+	if err := inputText(page, secondFactorSelector, code); err != nil {
+		return apperr.Wrap(err, apperr.SelectorNotFound, "无法找到安全验证输入框")
+	}
+	submit, err := page.Element(secondFactorSubmit)
+	if err != nil {
+		return apperr.Wrap(err, apperr.SelectorNotFound, "无法找到安全验证提交按钮")
+	}
+	if err := submit.Click(proto.InputMouseButtonLeft, 1); err != nil {
+		return apperr.Wrap(err, apperr.Internal, "无法提交安全验证")
+	}
+	time.Sleep(2 * time.Second)
+	return nil
+}
+
+// inputText 清空输入框后输入文本
+func inputText(page *rod.Page, selector, text string) error {
+	el, err := page.Element(selector)
+	if err != nil {
+		return err
+	}
+	if err := el.SelectAllText(); err != nil {
+		return err
+	}
+	return el.Input(text)
+}
+
+// setRemember 按需勾选或取消"下次自动登录"
+func setRemember(page *rod.Page, remember bool) error {
+	has, checkbox, _ := page.Has(rememberSelector)
+	if !has {
+		return nil
+	}
+	checked, err := checkbox.Property("checked")
+	if err != nil {
+		return err
+	}
+	if checked.Bool() == remember {
+		return nil
+	}
+	return checkbox.Click(proto.InputMouseButtonLeft, 1)
+}
+
 func (m *BaiduModule) Close(session *modules.Session) error {
 	if session.Browser != nil {
-		session.Browser.MustClose()
+		return session.Browser.Close()
 	}
 	return nil
 }
+
+// openLoginPage 打开百度登录页面并等待加载，出现人机验证时返回 captcha_detected
+// 在会话页面上导航，不新建标签页，重复获取二维码时也只有一个页面
+func openLoginPage(session *modules.Session) (*rod.Page, error) {
+	page := session.Page
+	if page == nil {
+		return nil, apperr.New(apperr.InvalidRequest, "会话页面未初始化")
+	}
+	if err := page.Navigate("https://passport.baidu.com/v2/?login"); err != nil {
+		return nil, apperr.Wrap(err, apperr.NavigationFailed, "无法打开百度登录页面")
+	}
+
+	// 等待页面加载
+	log.Println("等待页面加载...")
+	if err := page.WaitLoad(); err != nil {
+		return nil, apperr.Wrap(err, apperr.NavigationFailed, "百度登录页面加载失败")
+	}
+
+	if captcha, _ := modules.DetectCaptcha(page); captcha {
+		return nil, apperr.New(apperr.CaptchaDetected, "百度登录页面要求进行安全验证")
+	}
+	return page, nil
+}
@@ -0,0 +1,48 @@
+package modules
+
+import (
+	"github.com/go-rod/rod"
+)
+
+// 人机验证页面的常见特征：Cloudflare 拦截页、极验、阿里云滑块、腾讯验证码、百度安全验证等
+var captchaSelectors = []string{
+	"#challenge-form",
+	"#challenge-running",
+	"#cf-challenge-running",
+	".geetest_panel",
+	".geetest_holder",
+	"#nc_1_wrapper",
+	".nc-container",
+	"#tcaptcha_iframe",
+	"#tcaptcha_transform",
+	".vcode-spin",
+	".passMod_spin-wrap",
+	"iframe[src*='hcaptcha.com/captcha']",
+	"iframe[src*='recaptcha/api2/bframe']",
+}
+
+// 人机验证页面的标题特征
+var captchaTitles = []string{
+	"Just a moment...",
+	"Attention Required!",
+	"百度安全验证",
+	"安全验证",
+	"人机验证",
+}
+
+// 人机验证页面的地址特征
+var captchaURLs = []string{
+	"google.com/sorry",
+	"wappass.baidu.com/static/captcha",
+	"/captcha",
+}
+
+// DetectCaptcha 检查页面是否出现验证码或人机验证
+// 页面中有可见的验证码元素，或标题、地址命中人机验证特征时返回 true
+func DetectCaptcha(page *rod.Page) (bool, error) {
+	return detectSignals(page, pageSignals{
+		Selectors: captchaSelectors,
+		Titles:    captchaTitles,
+		URLs:      captchaURLs,
+	})
+}
@@ -1,9 +1,8 @@
 package daxuesoutijiang
 
 import (
-	"fmt"
 	"log"
-	"sync"
+	"textsurf/apperr"
 	"textsurf/modules"
 	"time"
 
@@ -11,13 +10,35 @@ import (
 	"github.com/go-rod/rod/lib/proto"
 )
 
+// 大学生搜题匠首页
+const homeURL = "https://www.daxuesoutijiang.com/"
+
+// 站点域名，判断会话页面是否已打开站点
+const siteHost = "daxuesoutijiang.com"
+
+// 二维码的大致有效期
 const qrImageTTL = 60 * time.Second
 
-type DaxuesoutijiangModule struct {
-	// 为每个模块实例添加一个互斥锁来保护页面访问
-	pageMutex sync.Mutex
+// 二维码 canvas 元素
+const qrCodeSelector = "#dx-login-dialog-container > div > div > div.login-by-qrcode-wrapper > div > div.login-by-qrcode-content > canvas"
+
+// 二维码过期时显示的遮罩和刷新按钮
+var qrRefreshSelectors = []string{
+	".login-by-qrcode-content .refresh",
+	".login-by-qrcode-content .qrcode-refresh",
+	".login-by-qrcode-content .expired",
+	".login-by-qrcode-content [class*='refresh']",
 }
 
+// 模块支持的登录方式
+var (
+	_ modules.QRLogin     = (*DaxuesoutijiangModule)(nil)
+	_ modules.QRRefresher = (*DaxuesoutijiangModule)(nil)
+)
+
+// DaxuesoutijiangModule 的页面操作使用会话自己的锁，同一模块的会话互不阻塞
+type DaxuesoutijiangModule struct{}
+
 func NewDaxuesoutijiangModule() modules.Module {
 	return &DaxuesoutijiangModule{}
 }
@@ -26,54 +47,40 @@ func (m *DaxuesoutijiangModule) Name() string {
 	return "daxuesoutijiang"
 }
 
+// Metadata 返回模块的描述信息
+func (m *DaxuesoutijiangModule) Metadata() modules.Metadata {
+	return modules.Metadata{
+		DisplayName:   "大学生搜题匠",
+		Site:          homeURL,
+		Version:       "1.0.0",
+		Inputs:        []modules.Input{},
+		QRLifetime:    int(qrImageTTL.Seconds()),
+		CookieDomains: []string{".daxuesoutijiang.com"},
+	}
+}
+
 func (m *DaxuesoutijiangModule) GetLoginQRCode(session *modules.Session) (string, error) {
-	m.pageMutex.Lock()
-	defer m.pageMutex.Unlock()
+	session.LockPage()
+	defer session.UnlockPage()
 
 	// 访问大学生搜题匠首页
 	log.Println("正在访问大学生搜题匠首页...")
-	page := session.Browser.MustPage("https://www.daxuesoutijiang.com/")
-	session.Page = page
-
-	// 等待页面加载
-	log.Println("等待页面加载...")
-	page.MustWaitLoad()
-	time.Sleep(2 * time.Second)
-
-	// 点击登录按钮
-	log.Println("点击登录按钮...")
-	loginButton, err := page.Element("#main > div.header-container > header > div > div.header-nav > button")
-	if err != nil {
-		return "", fmt.Errorf("无法找到登录按钮: %v", err)
+	// 在会话页面上导航，不新建标签页，重复获取二维码时也只有一个页面
+	page := session.Page
+	if page == nil {
+		return "", apperr.New(apperr.InvalidRequest, "会话页面未初始化")
 	}
-	loginButton.MustClick()
-	time.Sleep(2 * time.Second)
-
-	// 等待二维码加载
-	log.Println("等待二维码加载...")
-	page.MustWaitLoad()
-	time.Sleep(5 * time.Second) // 增加等待时间
-
-	// 查找二维码canvas元素
-	log.Println("查找二维码canvas元素...")
-	qrElement, err := page.Element("#dx-login-dialog-container > div > div > div.login-by-qrcode-wrapper > div > div.login-by-qrcode-content > canvas")
-	if err != nil {
-		log.Println("无法找到二维码canvas元素: ", err)
-		return "", fmt.Errorf("无法找到二维码canvas元素: %v", err)
+	if err := page.Navigate(homeURL); err != nil {
+		return "", apperr.Wrap(err, apperr.NavigationFailed, "无法打开大学生搜题匠首页")
 	}
 
-	log.Println("找到二维码canvas元素，尝试获取截图...")
-
-	// 直接获取canvas元素的截图
-	imgBytes, err := qrElement.Screenshot(proto.PageCaptureScreenshotFormatPng, 100)
-	if err != nil {
-		return "", fmt.Errorf("无法获取canvas截图: %v", err)
+	if err := openLoginDialog(page); err != nil {
+		return "", err
 	}
 
-	// 将图片保存到会话数据中
-	session.Data["qrImage"] = imgBytes
-	session.Data["qrImageFetchedAt"] = time.Now()
-	log.Println("成功获取二维码图片内容")
+	if _, err := captureQRCode(session, page); err != nil {
+		return "", err
+	}
 
 	// 返回一个标识符，表示图片已保存在会话中
 	return "session_image", nil
@@ -81,11 +88,9 @@ func (m *DaxuesoutijiangModule) GetLoginQRCode(session *modules.Session) (string
 
 // GetLoginQRCodeImage 获取登录二维码图片内容
 func (m *DaxuesoutijiangModule) GetLoginQRCodeImage(session *modules.Session) ([]byte, error) {
-	// 如果已经获取过二维码图片，直接返回
-	if imgData, ok := session.Data["qrImage"].([]byte); ok {
-		if fetchedAt, tsOK := session.Data["qrImageFetchedAt"].(time.Time); tsOK && time.Since(fetchedAt) < qrImageTTL {
-			return imgData, nil
-		}
+	// 如果已经获取过二维码图片且仍在有效期内，直接返回
+	if qr, ok := session.QR.Current(); ok && !qr.Expired() && qr.Image != nil {
+		return qr.Image, nil
 	}
 
 	// 否则重新获取二维码
@@ -95,57 +100,158 @@ func (m *DaxuesoutijiangModule) GetLoginQRCodeImage(session *modules.Session) ([
 	}
 
 	// 再次尝试获取图片数据
-	if imgData, ok := session.Data["qrImage"].([]byte); ok {
-		return imgData, nil
+	if qr, ok := session.QR.Current(); ok && qr.Image != nil {
+		return qr.Image, nil
+	}
+
+	return nil, apperr.New(apperr.Internal, "无法获取二维码图片")
+}
+
+// RefreshQRCode 检查登录弹窗中的二维码是否过期，过期时点击刷新按钮，没有刷新按钮时重新加载页面并打开登录弹窗
+func (m *DaxuesoutijiangModule) RefreshQRCode(session *modules.Session) (bool, error) {
+	current, ok := session.QR.Current()
+	if !ok || session.Page == nil {
+		return false, nil
+	}
+
+	session.LockPage()
+	defer session.UnlockPage()
+
+	page := session.Page
+	expired, err := modules.DetectQRExpired(page, qrRefreshSelectors)
+	if err != nil {
+		return false, apperr.Wrap(err, apperr.Internal, "检查二维码状态失败")
+	}
+	if !expired && !current.Expired() {
+		return false, nil
+	}
+
+	log.Println("大学生搜题匠二维码已过期，正在刷新...")
+	if clickRefresh(page) {
+		time.Sleep(2 * time.Second)
+	} else {
+		log.Println("未找到刷新按钮，重新加载页面...")
+		if err := page.Reload(); err != nil {
+			return false, apperr.Wrap(err, apperr.NavigationFailed, "重新加载大学生搜题匠首页失败")
+		}
+		if err := openLoginDialog(page); err != nil {
+			return false, err
+		}
 	}
 
-	return nil, fmt.Errorf("无法获取二维码图片")
+	if _, err := captureQRCode(session, page); err != nil {
+		return false, err
+	}
+	return true, nil
 }
 
-func (m *DaxuesoutijiangModule) PrepareSMSLogin(session *modules.Session) (map[string]interface{}, error) {
-	return nil, fmt.Errorf("大学生搜题匠模块不支持短信登录")
+// openLoginDialog 等待首页加载后点击登录按钮，打开二维码登录弹窗
+func openLoginDialog(page *rod.Page) error {
+	// 等待页面加载
+	log.Println("等待页面加载...")
+	if err := page.WaitLoad(); err != nil {
+		return apperr.Wrap(err, apperr.NavigationFailed, "大学生搜题匠首页加载失败")
+	}
+	time.Sleep(2 * time.Second)
+
+	if captcha, _ := modules.DetectCaptcha(page); captcha {
+		return apperr.New(apperr.CaptchaDetected, "大学生搜题匠首页要求进行人机验证")
+	}
+
+	// 点击登录按钮
+	log.Println("点击登录按钮...")
+	loginButton, err := page.Element("#main > div.header-container > header > div > div.header-nav > button")
+	if err != nil {
+		return apperr.Wrap(err, apperr.SelectorNotFound, "无法找到登录按钮")
+	}
+	if err := loginButton.Click(proto.InputMouseButtonLeft, 1); err != nil {
+		return apperr.Wrap(err, apperr.Internal, "无法点击登录按钮")
+	}
+	time.Sleep(2 * time.Second)
+
+	// 等待二维码加载
+	log.Println("等待二维码加载...")
+	if err := page.WaitLoad(); err != nil {
+		return apperr.Wrap(err, apperr.NavigationFailed, "二维码加载失败")
+	}
+	time.Sleep(5 * time.Second) // 增加等待时间
+	return nil
 }
 
-func (m *DaxuesoutijiangModule) SendSMSCode(session *modules.Session, phoneNumber string) error {
-	return fmt.Errorf("大学生搜题匠模块不支持短信登录")
+// clickRefresh 点击二维码的刷新按钮，没有可见的刷新按钮时返回 false
+func clickRefresh(page *rod.Page) bool {
+	for _, selector := range qrRefreshSelectors {
+		has, el, _ := page.Has(selector)
+		if !has {
+			continue
+		}
+		if visible, _ := el.Visible(); !visible {
+			continue
+		}
+		if err := el.Click(proto.InputMouseButtonLeft, 1); err == nil {
+			return true
+		}
+	}
+	return false
 }
 
-func (m *DaxuesoutijiangModule) VerifySMSCode(session *modules.Session, smsCode string) error {
-	return fmt.Errorf("大学生搜题匠模块不支持短信登录")
+// captureQRCode 截取二维码 canvas，记录为会话的新二维码
+func captureQRCode(session *modules.Session, page *rod.Page) (modules.QRCode, error) {
+	// 查找二维码canvas元素
+	log.Println("查找二维码canvas元素...")
+	qrElement, err := page.Element(qrCodeSelector)
+	if err != nil {
+		log.Println("无法找到二维码canvas元素: ", err)
+		return modules.QRCode{}, apperr.Wrap(err, apperr.SelectorNotFound, "无法找到二维码canvas元素")
+	}
+
+	log.Println("找到二维码canvas元素，尝试获取截图...")
+
+	// 直接获取canvas元素的截图
+	imgBytes, err := qrElement.Screenshot(proto.PageCaptureScreenshotFormatPng, 100)
+	if err != nil {
+		return modules.QRCode{}, apperr.Wrap(err, apperr.Internal, "无法获取canvas截图")
+	}
+
+	qr := modules.UpdateQRCode(session, imgBytes, "", qrImageTTL)
+	log.Printf("成功获取二维码图片内容 (版本 %d)\n", qr.Version)
+	return qr, nil
 }
 
 func (m *DaxuesoutijiangModule) CheckLogin(session *modules.Session) (bool, map[string]string, error) {
 	// 检查页面是否已初始化
 	if session.Page == nil {
-		return false, nil, fmt.Errorf("会话页面未初始化，请先获取登录二维码")
+		return false, nil, apperr.New(apperr.InvalidRequest, "会话页面未初始化，请先获取登录二维码")
 	}
 
 	// 使用互斥锁保护对页面的访问，防止并发访问导致的竞态条件
-	m.pageMutex.Lock()
-	defer m.pageMutex.Unlock()
+	session.LockPage()
+	defer session.UnlockPage()
 
-	// 检查浏览器实例是否仍然活跃
-	if err := rod.Try(func() {
-		// 测试连接是否仍然有效
-		session.Page.MustInfo()
-	}); err != nil {
-		return false, nil, fmt.Errorf("浏览器连接已关闭: %v", err)
+	// 检查浏览器实例是否仍然活跃，连接已关闭时返回 browser_crashed
+	info, err := session.Page.Info()
+	if err != nil {
+		return false, nil, apperr.Wrap(err, apperr.BrowserCrashed, "浏览器连接已关闭")
 	}
 
 	// 检查当前页面URL
-	currentURL := session.Page.MustInfo().URL
+	currentURL := info.URL
 	log.Printf("当前页面URL: %s", currentURL)
 
+	// 会话页面还是空白页或已离开站点时，不能据 URL 判断登录结果
+	onSite := modules.OnSite(currentURL, siteHost)
+
 	// 如果URL已经不是登录页面，说明可能已登录成功
-	// 或者检查是否有用户头像元素（表明已登录）
-	_, avatarErr := session.Page.Element("#avatar")
+	// 或者检查是否有用户头像元素（表明已登录），Has 不等待元素出现
+	hasAvatar, _, _ := session.Page.Has("#avatar")
+	urlChanged := onSite && currentURL != homeURL
 
 	// 登录成功后通常会跳转到首页或其他页面
-	if currentURL != "https://www.daxuesoutijiang.com/" || avatarErr == nil {
+	if urlChanged || hasAvatar {
 		// 获取cookies
 		cookies, err := session.Page.Cookies([]string{})
 		if err != nil {
-			return false, nil, fmt.Errorf("获取cookies失败: %v", err)
+			return false, nil, apperr.Wrap(err, apperr.Internal, "获取cookies失败")
 		}
 
 		// 转换为字符串map
@@ -155,15 +261,19 @@ func (m *DaxuesoutijiangModule) CheckLogin(session *modules.Session) (bool, map[
 		}
 
 		// 如果有avatar元素或者URL已改变，则认为已登录
-		if avatarErr == nil || currentURL != "https://www.daxuesoutijiang.com/" {
+		if hasAvatar || urlChanged {
 			return true, cookieMap, nil
 		}
 	}
 
+	// 检查是否需要人机验证
+	if captcha, _ := modules.DetectCaptcha(session.Page); captcha {
+		return false, nil, apperr.New(apperr.CaptchaDetected, "登录需要进行人机验证")
+	}
+
 	// 检查是否有登录失败的提示
-	_, err := session.Page.Element(".error-message")
-	if err == nil {
-		return false, nil, fmt.Errorf("登录失败，请重新尝试")
+	if has, _, _ := session.Page.Has(".error-message"); has {
+		return false, nil, apperr.New(apperr.LoginRejected, "登录失败，请重新尝试")
 	}
 
 	// 如果都没有明确结果，返回未登录状态
@@ -172,7 +282,7 @@ func (m *DaxuesoutijiangModule) CheckLogin(session *modules.Session) (bool, map[
 
 func (m *DaxuesoutijiangModule) Close(session *modules.Session) error {
 	if session.Browser != nil {
-		session.Browser.MustClose()
+		return session.Browser.Close()
 	}
 	return nil
 }
@@ -0,0 +1,240 @@
+package declarative
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"textsurf/actions"
+	"textsurf/modules"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 模块名称只能包含小写字母、数字、下划线和连字符，用于 URL 路径
+var nameRegexp = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
+// Definition 声明式模块的定义文件，支持 YAML 和 JSON
+type Definition struct {
+	Name          string   `json:"name"`
+	DisplayName   string   `json:"display_name"`
+	Site          string   `json:"site"`
+	Version       string   `json:"version"`
+	LoginURL      string   `json:"login_url"`
+	CookieDomains []string `json:"cookie_domains"`
+
+	// QR、SMS、Password 为各登录方式的步骤，未定义的登录方式不启用
+	QR       *QRFlow       `json:"qr"`
+	SMS      *SMSFlow      `json:"sms"`
+	Password *PasswordFlow `json:"password"`
+
+	// Success 登录成功的判断条件，满足任一条件即视为登录成功
+	Success Predicates `json:"success"`
+	// Failure 登录失败的判断条件，满足任一条件时返回 login_rejected
+	Failure Predicates `json:"failure"`
+}
+
+// QRFlow 扫码登录的步骤
+type QRFlow struct {
+	// Steps 打开登录页面后显示二维码的操作，例如切换到扫码登录
+	Steps []actions.Action `json:"steps"`
+	// Selector 二维码元素，img 元素读取图片地址，其他元素截图
+	Selector string `json:"selector"`
+	// LifetimeSeconds 二维码的大致有效期，默认 120 秒
+	LifetimeSeconds int `json:"lifetime_seconds"`
+	// RefreshSelectors 二维码过期时显示的遮罩或刷新按钮，依次尝试点击
+	RefreshSelectors []string `json:"refresh_selectors"`
+}
+
+// SMSFlow 短信验证码登录的步骤
+// send 和 verify 步骤的 text、value 中可以使用 {{phone_number}} 和 {{sms_code}}
+type SMSFlow struct {
+	Prepare []actions.Action `json:"prepare"`
+	Send    []actions.Action `json:"send"`
+	Verify  []actions.Action `json:"verify"`
+	// PhonePattern、CodePattern 手机号和验证码的格式，为空时不校验
+	PhonePattern string `json:"phone_pattern"`
+	CodePattern  string `json:"code_pattern"`
+}
+
+// PasswordFlow 账号密码登录的步骤
+// fill 步骤的 text、value 中可以使用 {{username}} 和 {{password}}
+type PasswordFlow struct {
+	Fill []actions.Action `json:"fill"`
+	// Remember 请求勾选"记住我"时在提交前执行
+	Remember []actions.Action `json:"remember"`
+	Submit   []actions.Action `json:"submit"`
+	// SecondFactor 提交后要求二次验证时的判断条件和提交步骤
+	SecondFactor *SecondFactorFlow `json:"second_factor"`
+}
+
+// SecondFactorFlow 二次验证的步骤
+// steps 的 text、value 中可以使用 {{code}}
+type SecondFactorFlow struct {
+	// Selectors 二次验证弹窗或输入框，出现任一元素时返回 second_factor_required
+	Selectors []string         `json:"selectors"`
+	Steps     []actions.Action `json:"steps"`
+}
+
+// Predicates 按页面地址或元素判断登录结果
+type Predicates struct {
+	// URLPatterns 当前页面地址的正则表达式
+	URLPatterns []string `json:"url_patterns"`
+	// Selectors 页面上出现的元素
+	Selectors []string `json:"selectors"`
+}
+
+// Load 加载目录中所有 .yaml、.yml 和 .json 定义文件，按文件名顺序返回模块
+func Load(dir string) ([]modules.Module, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("读取模块定义目录失败: %w", err)
+	}
+
+	var files []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		switch strings.ToLower(filepath.Ext(entry.Name())) {
+		case ".yaml", ".yml", ".json":
+			files = append(files, filepath.Join(dir, entry.Name()))
+		}
+	}
+	sort.Strings(files)
+
+	loaded := make([]modules.Module, 0, len(files))
+	names := make(map[string]string)
+	for _, file := range files {
+		def, err := LoadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if previous, exists := names[def.Name]; exists {
+			return nil, fmt.Errorf("模块 '%s' 在 %s 和 %s 中重复定义", def.Name, previous, file)
+		}
+		names[def.Name] = file
+		loaded = append(loaded, New(def))
+	}
+	return loaded, nil
+}
+
+// LoadFile 读取并校验单个定义文件
+func LoadFile(path string) (*Definition, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取模块定义文件失败: %w", err)
+	}
+
+	def, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("模块定义文件 %s 无效: %w", path, err)
+	}
+	return def, nil
+}
+
+// Parse 解析并校验定义内容，JSON 作为 YAML 的子集解析
+func Parse(data []byte) (*Definition, error) {
+	// 操作步骤沿用 /fetch 的 JSON 字段名，先解析为通用结构再按 JSON 标签解码
+	var raw interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("解析失败: %w", err)
+	}
+	encoded, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("解析失败: %w", err)
+	}
+
+	def := &Definition{}
+	decoder := json.NewDecoder(strings.NewReader(string(encoded)))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(def); err != nil {
+		return nil, fmt.Errorf("解析失败: %w", err)
+	}
+
+	if err := def.validate(); err != nil {
+		return nil, err
+	}
+	return def, nil
+}
+
+// validate 校验必需字段、操作步骤和正则表达式
+func (d *Definition) validate() error {
+	if !nameRegexp.MatchString(d.Name) {
+		return fmt.Errorf("name 只能包含小写字母、数字、下划线和连字符")
+	}
+	if d.LoginURL == "" {
+		return fmt.Errorf("缺少 login_url")
+	}
+	if d.QR == nil && d.SMS == nil && d.Password == nil {
+		return fmt.Errorf("至少需要定义 qr、sms、password 中的一种登录方式")
+	}
+	if len(d.Success.URLPatterns) == 0 && len(d.Success.Selectors) == 0 {
+		return fmt.Errorf("success 至少需要一个 url_patterns 或 selectors 条件")
+	}
+
+	type stepList struct {
+		name  string
+		steps []actions.Action
+	}
+	var steps []stepList
+	patterns := map[string]string{}
+	if d.QR != nil {
+		if d.QR.Selector == "" {
+			return fmt.Errorf("缺少 qr.selector")
+		}
+		if d.QR.LifetimeSeconds < 0 {
+			return fmt.Errorf("qr.lifetime_seconds 不能为负数")
+		}
+		steps = append(steps, stepList{"qr.steps", d.QR.Steps})
+	}
+	if d.SMS != nil {
+		if len(d.SMS.Send) == 0 || len(d.SMS.Verify) == 0 {
+			return fmt.Errorf("sms 需要 send 和 verify 步骤")
+		}
+		steps = append(steps,
+			stepList{"sms.prepare", d.SMS.Prepare},
+			stepList{"sms.send", d.SMS.Send},
+			stepList{"sms.verify", d.SMS.Verify})
+		patterns["sms.phone_pattern"] = d.SMS.PhonePattern
+		patterns["sms.code_pattern"] = d.SMS.CodePattern
+	}
+	if d.Password != nil {
+		if len(d.Password.Fill) == 0 || len(d.Password.Submit) == 0 {
+			return fmt.Errorf("password 需要 fill 和 submit 步骤")
+		}
+		steps = append(steps,
+			stepList{"password.fill", d.Password.Fill},
+			stepList{"password.remember", d.Password.Remember},
+			stepList{"password.submit", d.Password.Submit})
+		if d.Password.SecondFactor != nil {
+			if len(d.Password.SecondFactor.Steps) == 0 {
+				return fmt.Errorf("password.second_factor 需要 steps")
+			}
+			steps = append(steps, stepList{"password.second_factor.steps", d.Password.SecondFactor.Steps})
+		}
+	}
+
+	for _, list := range steps {
+		if err := actions.Validate(list.steps); err != nil {
+			return fmt.Errorf("%s: %v", list.name, err)
+		}
+	}
+	for name, pattern := range patterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("%s 不是有效的正则表达式: %v", name, err)
+		}
+	}
+	for _, list := range [][]string{d.Success.URLPatterns, d.Failure.URLPatterns} {
+		for _, pattern := range list {
+			if _, err := regexp.Compile(pattern); err != nil {
+				return fmt.Errorf("url_patterns 中的 '%s' 不是有效的正则表达式: %v", pattern, err)
+			}
+		}
+	}
+	return nil
+}
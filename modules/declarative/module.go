@@ -0,0 +1,497 @@
+package declarative
+
+import (
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"textsurf/actions"
+	"textsurf/apperr"
+	"textsurf/modules"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/proto"
+)
+
+// 未指定 qr.lifetime_seconds 时二维码的有效期
+const defaultQRLifetime = 2 * time.Minute
+
+// 模块实现所有登录接口，实际启用的登录方式由 Supports 按定义决定
+var (
+	_ modules.QRLogin          = (*Module)(nil)
+	_ modules.QRRefresher      = (*Module)(nil)
+	_ modules.SMSLogin         = (*Module)(nil)
+	_ modules.PasswordLogin    = (*Module)(nil)
+	_ modules.CapabilityFilter = (*Module)(nil)
+)
+
+// Module 按定义文件执行登录流程的模块
+type Module struct {
+	def     *Definition
+	success []*regexp.Regexp
+	failure []*regexp.Regexp
+}
+
+// New 创建声明式模块，定义需已通过 Parse 校验
+func New(def *Definition) *Module {
+	return &Module{
+		def:     def,
+		success: compilePatterns(def.Success.URLPatterns),
+		failure: compilePatterns(def.Failure.URLPatterns),
+	}
+}
+
+// compilePatterns 编译已校验过的正则表达式
+func compilePatterns(patterns []string) []*regexp.Regexp {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		compiled = append(compiled, regexp.MustCompile(pattern))
+	}
+	return compiled
+}
+
+func (m *Module) Name() string {
+	return m.def.Name
+}
+
+// Metadata 返回定义文件中的描述信息，输入项按启用的登录方式生成
+func (m *Module) Metadata() modules.Metadata {
+	metadata := modules.Metadata{
+		DisplayName:   m.def.DisplayName,
+		Site:          m.def.Site,
+		Version:       m.def.Version,
+		Inputs:        []modules.Input{},
+		CookieDomains: m.def.CookieDomains,
+	}
+	if m.def.QR != nil {
+		metadata.QRLifetime = int(m.qrLifetime().Seconds())
+	}
+	if m.def.SMS != nil {
+		metadata.Inputs = append(metadata.Inputs,
+			modules.Input{Name: "phone_number", Method: modules.CapabilitySMS, Label: "手机号", Pattern: m.def.SMS.PhonePattern},
+			modules.Input{Name: "sms_code", Method: modules.CapabilitySMS, Label: "短信验证码", Pattern: m.def.SMS.CodePattern},
+		)
+	}
+	if m.def.Password != nil {
+		metadata.Inputs = append(metadata.Inputs,
+			modules.Input{Name: "username", Method: modules.CapabilityPassword, Label: "账号"},
+			modules.Input{Name: "password", Method: modules.CapabilityPassword, Label: "密码", Secret: true},
+		)
+		if m.def.Password.SecondFactor != nil {
+			metadata.Inputs = append(metadata.Inputs,
+				modules.Input{Name: "code", Method: modules.CapabilityPassword, Label: "二次验证码"})
+		}
+	}
+	return metadata
+}
+
+// Supports 返回定义文件中是否定义了该登录方式
+func (m *Module) Supports(capability string) bool {
+	switch capability {
+	case modules.CapabilityQR:
+		return m.def.QR != nil
+	case modules.CapabilitySMS:
+		return m.def.SMS != nil
+	case modules.CapabilityPassword:
+		return m.def.Password != nil
+	}
+	return false
+}
+
+// qrLifetime 二维码有效期
+func (m *Module) qrLifetime() time.Duration {
+	if m.def.QR.LifetimeSeconds > 0 {
+		return time.Duration(m.def.QR.LifetimeSeconds) * time.Second
+	}
+	return defaultQRLifetime
+}
+
+func (m *Module) GetLoginQRCode(session *modules.Session) (string, error) {
+	qr, err := m.loadQRCode(session)
+	if err != nil {
+		return "", err
+	}
+	return qr.URL, nil
+}
+
+func (m *Module) GetLoginQRCodeImage(session *modules.Session) ([]byte, error) {
+	// 如果已经获取过二维码图片且仍在有效期内，直接返回
+	if qr, ok := session.QR.Current(); ok && !qr.Expired() && qr.Image != nil {
+		return qr.Image, nil
+	}
+
+	qr, err := m.loadQRCode(session)
+	if err != nil {
+		return nil, err
+	}
+	return qr.Image, nil
+}
+
+// loadQRCode 打开登录页面，执行显示二维码的步骤后读取二维码
+func (m *Module) loadQRCode(session *modules.Session) (modules.QRCode, error) {
+	if m.def.QR == nil {
+		return modules.QRCode{}, apperr.New(apperr.Unsupported, "模块 %s 未定义扫码登录", m.def.Name)
+	}
+
+	session.LockPage()
+	defer session.UnlockPage()
+
+	page, err := m.openLoginPage(session)
+	if err != nil {
+		return modules.QRCode{}, err
+	}
+	if err := m.run(page, m.def.QR.Steps, nil); err != nil {
+		return modules.QRCode{}, err
+	}
+	return m.captureQRCode(session, page)
+}
+
+// RefreshQRCode 检查二维码是否过期，过期时点击刷新按钮，没有刷新按钮时重新打开登录页面
+func (m *Module) RefreshQRCode(session *modules.Session) (bool, error) {
+	if m.def.QR == nil {
+		return false, nil
+	}
+	current, ok := session.QR.Current()
+	if !ok || session.Page == nil {
+		return false, nil
+	}
+
+	session.LockPage()
+	defer session.UnlockPage()
+
+	page := session.Page
+	expired, err := modules.DetectQRExpired(page, m.def.QR.RefreshSelectors)
+	if err != nil {
+		return false, apperr.Wrap(err, apperr.Internal, "检查二维码状态失败")
+	}
+	if !expired && !current.Expired() {
+		return false, nil
+	}
+
+	log.Printf("%s 二维码已过期，正在刷新...\n", m.def.Name)
+	if m.clickRefresh(page) {
+		time.Sleep(2 * time.Second)
+	} else {
+		log.Println("未找到刷新按钮，重新打开登录页面...")
+		if page, err = m.openLoginPage(session); err != nil {
+			return false, err
+		}
+		if err := m.run(page, m.def.QR.Steps, nil); err != nil {
+			return false, err
+		}
+	}
+
+	if _, err := m.captureQRCode(session, page); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// clickRefresh 点击第一个可见的刷新按钮，没有可点击的按钮时返回 false
+func (m *Module) clickRefresh(page *rod.Page) bool {
+	for _, selector := range m.def.QR.RefreshSelectors {
+		has, el, _ := page.Has(selector)
+		if !has {
+			continue
+		}
+		if visible, _ := el.Visible(); !visible {
+			continue
+		}
+		if err := el.Click(proto.InputMouseButtonLeft, 1); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// captureQRCode 读取二维码元素，img 元素下载图片，其他元素截图
+func (m *Module) captureQRCode(session *modules.Session, page *rod.Page) (modules.QRCode, error) {
+	el, err := page.Element(m.def.QR.Selector)
+	if err != nil {
+		return modules.QRCode{}, apperr.Wrap(err, apperr.SelectorNotFound, "无法找到二维码元素 '%s'", m.def.QR.Selector)
+	}
+	if err := el.WaitVisible(); err != nil {
+		return modules.QRCode{}, apperr.Wrap(err, apperr.SelectorNotFound, "二维码元素 '%s' 未显示", m.def.QR.Selector)
+	}
+
+	var src string
+	var img []byte
+	if tag, err := el.Eval(`() => this.tagName`); err == nil && strings.EqualFold(tag.Value.Str(), "img") {
+		if attr, _ := el.Attribute("src"); attr != nil {
+			src = *attr
+		}
+		img, err = el.Resource()
+		if err != nil {
+			log.Printf("下载二维码图片失败，改为截图: %v\n", err)
+		}
+	}
+	if img == nil {
+		img, err = el.Screenshot(proto.PageCaptureScreenshotFormatPng, 100)
+		if err != nil {
+			return modules.QRCode{}, apperr.Wrap(err, apperr.Internal, "无法截取二维码")
+		}
+	}
+
+	qr := modules.UpdateQRCode(session, img, src, m.qrLifetime())
+	log.Printf("成功获取 %s 二维码 (版本 %d)\n", m.def.Name, qr.Version)
+	return qr, nil
+}
+
+func (m *Module) PrepareSMSLogin(session *modules.Session) (map[string]interface{}, error) {
+	if m.def.SMS == nil {
+		return nil, apperr.New(apperr.Unsupported, "模块 %s 未定义短信登录", m.def.Name)
+	}
+
+	session.LockPage()
+	defer session.UnlockPage()
+
+	page, err := m.openLoginPage(session)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.run(page, m.def.SMS.Prepare, nil); err != nil {
+		return nil, err
+	}
+
+	pageInfo, err := page.Info()
+	if err != nil {
+		return nil, apperr.Wrap(err, apperr.BrowserCrashed, "无法读取页面信息")
+	}
+	return map[string]interface{}{
+		"status":     "ready",
+		"login_type": "sms",
+		"url":        pageInfo.URL,
+	}, nil
+}
+
+func (m *Module) SendSMSCode(session *modules.Session, phoneNumber string) error {
+	return m.runOnLoginPage(session, func(page *rod.Page) error {
+		return m.run(page, m.def.SMS.Send, map[string]string{"phone_number": phoneNumber})
+	})
+}
+
+func (m *Module) VerifySMSCode(session *modules.Session, smsCode string) error {
+	return m.runOnLoginPage(session, func(page *rod.Page) error {
+		return m.run(page, m.def.SMS.Verify, map[string]string{"sms_code": smsCode})
+	})
+}
+
+// LoginWithPassword 打开登录页面，填写账号密码后提交，按页面状态返回是否需要验证码或二次验证
+func (m *Module) LoginWithPassword(session *modules.Session, credentials modules.PasswordCredentials) (modules.PasswordResult, error) {
+	if m.def.Password == nil {
+		return modules.PasswordResult{}, apperr.New(apperr.Unsupported, "模块 %s 未定义账号密码登录", m.def.Name)
+	}
+
+	session.LockPage()
+	defer session.UnlockPage()
+
+	page, err := m.openLoginPage(session)
+	if err != nil {
+		return modules.PasswordResult{}, err
+	}
+
+	vars := map[string]string{
+		"username": credentials.Username,
+		"password": credentials.Password,
+	}
+	if err := m.run(page, m.def.Password.Fill, vars); err != nil {
+		return modules.PasswordResult{}, err
+	}
+	if credentials.Remember {
+		if err := m.run(page, m.def.Password.Remember, nil); err != nil {
+			return modules.PasswordResult{}, err
+		}
+	}
+	// 提交后出现验证码属于中间状态，不作为错误返回
+	if err := m.runSteps(page, m.def.Password.Submit, nil); err != nil {
+		return modules.PasswordResult{}, err
+	}
+
+	if captcha, _ := modules.DetectCaptcha(page); captcha {
+		return modules.PasswordResult{State: modules.PasswordCaptchaRequired, Message: "站点要求完成验证码"}, nil
+	}
+	pageInfo, err := page.Info()
+	if err != nil {
+		return modules.PasswordResult{}, apperr.Wrap(err, apperr.BrowserCrashed, "无法读取页面信息")
+	}
+	if matchPage(page, pageInfo.URL, m.failure, m.def.Failure.Selectors) {
+		return modules.PasswordResult{}, apperr.New(apperr.LoginRejected, "%s 拒绝了登录请求，请检查账号和密码", m.def.Name)
+	}
+	if factor := m.def.Password.SecondFactor; factor != nil {
+		if required, _ := modules.DetectSecondFactor(page, factor.Selectors); required {
+			return modules.PasswordResult{State: modules.PasswordSecondFactorRequired, Message: "站点要求输入二次验证码"}, nil
+		}
+	}
+	return modules.PasswordResult{State: modules.PasswordSubmitted}, nil
+}
+
+// SubmitSecondFactor 在登录页面上执行二次验证的步骤
+func (m *Module) SubmitSecondFactor(session *modules.Session, code string) error {
+	if m.def.Password == nil || m.def.Password.SecondFactor == nil {
+		return apperr.New(apperr.Unsupported, "模块 %s 未定义二次验证", m.def.Name)
+	}
+	if session.Page == nil {
+		return apperr.New(apperr.InvalidRequest, "会话页面未初始化，请先提交账号密码")
+	}
+
+	session.LockPage()
+	defer session.UnlockPage()
+	return m.run(session.Page, m.def.Password.SecondFactor.Steps, map[string]string{"code": code})
+}
+
+// runOnLoginPage 在 PrepareSMSLogin 打开的登录页面上执行短信登录的步骤
+func (m *Module) runOnLoginPage(session *modules.Session, fn func(page *rod.Page) error) error {
+	if m.def.SMS == nil {
+		return apperr.New(apperr.Unsupported, "模块 %s 未定义短信登录", m.def.Name)
+	}
+	if session.Page == nil {
+		return apperr.New(apperr.InvalidRequest, "会话页面未初始化，请先准备登录页面")
+	}
+
+	session.LockPage()
+	defer session.UnlockPage()
+	return fn(session.Page)
+}
+
+// openLoginPage 在会话页面上打开登录地址并等待加载，出现人机验证时返回 captcha_detected
+func (m *Module) openLoginPage(session *modules.Session) (*rod.Page, error) {
+	page := session.Page
+	if page == nil {
+		return nil, apperr.New(apperr.InvalidRequest, "会话页面未初始化")
+	}
+
+	log.Printf("正在打开 %s 登录页面: %s\n", m.def.Name, m.def.LoginURL)
+	if err := page.Navigate(m.def.LoginURL); err != nil {
+		return nil, apperr.Wrap(err, apperr.NavigationFailed, "无法打开 %s 登录页面", m.def.Name)
+	}
+	if err := page.WaitLoad(); err != nil {
+		return nil, apperr.Wrap(err, apperr.NavigationFailed, "%s 登录页面加载失败", m.def.Name)
+	}
+
+	// 重新打开登录页面后之前缓存的 cookies 不再有效
+	delete(session.Data, "cookies")
+
+	if captcha, _ := modules.DetectCaptcha(page); captcha {
+		return nil, apperr.New(apperr.CaptchaDetected, "%s 登录页面要求进行人机验证", m.def.Name)
+	}
+	return page, nil
+}
+
+// run 替换步骤中的占位符后执行，执行后出现人机验证时返回 captcha_detected
+func (m *Module) run(page *rod.Page, steps []actions.Action, vars map[string]string) error {
+	if err := m.runSteps(page, steps, vars); err != nil {
+		return err
+	}
+	if captcha, _ := modules.DetectCaptcha(page); captcha {
+		return apperr.New(apperr.CaptchaDetected, "%s 登录需要进行人机验证", m.def.Name)
+	}
+	return nil
+}
+
+// runSteps 替换步骤中的占位符后执行
+func (m *Module) runSteps(page *rod.Page, steps []actions.Action, vars map[string]string) error {
+	if _, err := actions.Run(page, substitute(steps, vars), nil); err != nil {
+		return apperr.From(err, apperr.Internal)
+	}
+	return nil
+}
+
+// substitute 将步骤 text、value 中的 {{name}} 替换为用户输入
+// 只替换输入框的内容，不替换脚本和地址，避免用户输入被当作代码执行
+func substitute(steps []actions.Action, vars map[string]string) []actions.Action {
+	if len(vars) == 0 {
+		return steps
+	}
+
+	pairs := make([]string, 0, len(vars)*2)
+	for name, value := range vars {
+		pairs = append(pairs, "{{"+name+"}}", value)
+	}
+	replacer := strings.NewReplacer(pairs...)
+
+	replaced := make([]actions.Action, len(steps))
+	for i, step := range steps {
+		step.Text = replacer.Replace(step.Text)
+		step.Value = replacer.Replace(step.Value)
+		replaced[i] = step
+	}
+	return replaced
+}
+
+// CheckLogin 按定义的成功和失败条件检查登录状态，登录成功时返回 cookie_domains 下的 cookies
+func (m *Module) CheckLogin(session *modules.Session) (bool, map[string]string, error) {
+	if session.Page == nil {
+		return false, nil, apperr.New(apperr.InvalidRequest, "会话页面未初始化，请先打开登录页面")
+	}
+
+	session.LockPage()
+	defer session.UnlockPage()
+
+	// 浏览器连接提前关闭时返回 browser_crashed
+	pageInfo, err := session.Page.Info()
+	if err != nil {
+		return false, nil, apperr.Wrap(err, apperr.BrowserCrashed, "浏览器连接已关闭")
+	}
+
+	if matchPage(session.Page, pageInfo.URL, m.success, m.def.Success.Selectors) {
+		if cached, ok := session.Data["cookies"].(map[string]string); ok && len(cached) > 0 {
+			return true, cached, nil
+		}
+
+		cookies, err := session.Page.Cookies(m.cookieURLs())
+		if err != nil {
+			return false, nil, apperr.Wrap(err, apperr.Internal, "获取cookies失败")
+		}
+		cookieMap := make(map[string]string, len(cookies))
+		for _, cookie := range cookies {
+			cookieMap[cookie.Name] = cookie.Value
+		}
+
+		// 缓存cookies到session中，支持多次获取
+		session.Data["cookies"] = cookieMap
+		log.Printf("%s 登录成功，获取到 %d 个cookies\n", m.def.Name, len(cookieMap))
+		return true, cookieMap, nil
+	}
+
+	if captcha, _ := modules.DetectCaptcha(session.Page); captcha {
+		return false, nil, apperr.New(apperr.CaptchaDetected, "%s 登录需要进行人机验证", m.def.Name)
+	}
+
+	if matchPage(session.Page, pageInfo.URL, m.failure, m.def.Failure.Selectors) {
+		return false, nil, apperr.New(apperr.LoginRejected, "%s 拒绝了登录请求", m.def.Name)
+	}
+
+	return false, nil, nil
+}
+
+// matchPage 判断页面地址匹配任一正则表达式，或页面上存在任一元素，Has 不等待元素出现
+func matchPage(page *rod.Page, url string, patterns []*regexp.Regexp, selectors []string) bool {
+	for _, pattern := range patterns {
+		if pattern.MatchString(url) {
+			return true
+		}
+	}
+	for _, selector := range selectors {
+		if has, _, _ := page.Has(selector); has {
+			return true
+		}
+	}
+	return false
+}
+
+// cookieURLs 将 cookie_domains 转换为读取 cookies 的地址，未定义时读取当前页面的 cookies
+func (m *Module) cookieURLs() []string {
+	urls := make([]string, 0, len(m.def.CookieDomains))
+	for _, domain := range m.def.CookieDomains {
+		urls = append(urls, "https://"+strings.TrimPrefix(domain, "."))
+	}
+	return urls
+}
+
+func (m *Module) Close(session *modules.Session) error {
+	if session.Browser != nil {
+		return session.Browser.Close()
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package modules
+
+import (
+	"github.com/go-rod/rod"
+)
+
+// pageSignals 页面特征，命中任一条件即返回 true
+type pageSignals struct {
+	// Selectors 页面上可见的元素
+	Selectors []string `json:"selectors"`
+	// Texts 页面可见文本中包含的文字
+	Texts []string `json:"texts"`
+	// Titles 页面标题中包含的文字
+	Titles []string `json:"titles"`
+	// URLs 页面地址中包含的文字
+	URLs []string `json:"urls"`
+}
+
+// 依次检查可见元素、可见文本、标题和地址，未设置的条件为 null
+const detectJS = `(signals) => {
+	const visible = el => {
+		const rect = el.getBoundingClientRect();
+		const style = getComputedStyle(el);
+		return rect.width > 0 && rect.height > 0 && style.visibility !== 'hidden' && style.display !== 'none';
+	};
+	for (const selector of signals.selectors || []) {
+		const el = document.querySelector(selector);
+		if (el && visible(el)) return true;
+	}
+	const includes = (value, list) => (list || []).some(t => value.includes(t));
+	if (signals.texts && includes(document.body ? document.body.innerText : '', signals.texts)) return true;
+	if (includes(document.title || '', signals.titles)) return true;
+	return includes(location.href, signals.urls);
+}`
+
+// detectSignals 检查页面是否命中 signals 中的任一特征
+func detectSignals(page *rod.Page, signals pageSignals) (bool, error) {
+	res, err := page.Eval(detectJS, signals)
+	if err != nil {
+		return false, err
+	}
+	return res.Value.Bool(), nil
+}
@@ -0,0 +1,198 @@
+package modules
+
+import (
+	"sync"
+	"time"
+)
+
+// 登录事件类型
+const (
+	// EventQRReady 第一次生成登录二维码
+	EventQRReady = "qr_ready"
+	// EventQRRefreshed 二维码已更新，之前的二维码失效
+	EventQRRefreshed = "qr_refreshed"
+	// EventQRScanned 用户已扫码，等待在手机上确认
+	EventQRScanned = "qr_scanned"
+	// EventSMSSent 短信验证码已发送
+	EventSMSSent = "sms_sent"
+	// EventPasswordSubmitted 账号密码已提交，等待登录结果
+	EventPasswordSubmitted = "password_submitted"
+	// EventCaptchaRequired 提交账号密码后站点要求完成验证码
+	EventCaptchaRequired = "captcha_required"
+	// EventSecondFactorRequired 提交账号密码后站点要求输入二次验证码
+	EventSecondFactorRequired = "second_factor_required"
+	// EventLoggedIn 登录成功
+	EventLoggedIn = "logged_in"
+	// EventFailed 登录失败，例如出现验证码、站点拒绝登录或浏览器崩溃
+	EventFailed = "failed"
+	// EventExpired 会话在登录前过期或被删除
+	EventExpired = "expired"
+)
+
+// 每个会话保留的历史事件数，新的订阅者先收到历史事件
+const maxEventHistory = 100
+
+// IsTerminal 判断事件是否表示登录流程结束
+func IsTerminal(eventType string) bool {
+	return eventType == EventLoggedIn || eventType == EventFailed || eventType == EventExpired
+}
+
+// Event 会话的登录事件
+type Event struct {
+	ID        int64                  `json:"id"`
+	Type      string                 `json:"type"`
+	Timestamp time.Time              `json:"timestamp"`
+	Data      map[string]interface{} `json:"data,omitempty"`
+
+	// Cookies 登录成功时的 cookies，只在服务端内部传递，不随事件流发送
+	Cookies map[string]string `json:"-"`
+}
+
+// Events 会话的事件中心，记录历史事件并广播给订阅者
+// 结束事件发布后不再接受新事件，所有订阅者的通道被关闭
+type Events struct {
+	mutex       sync.Mutex
+	history     []Event
+	subscribers map[chan Event]struct{}
+	nextID      int64
+	closed      bool
+	watching    bool
+	// finished 已发布结束事件
+	finished bool
+}
+
+// NewEvents 创建事件中心
+func NewEvents() *Events {
+	return &Events{
+		subscribers: make(map[chan Event]struct{}),
+	}
+}
+
+// Publish 发布事件，事件中心已关闭时忽略
+func (e *Events) Publish(event Event) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if e.closed {
+		return
+	}
+
+	e.nextID++
+	event.ID = e.nextID
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+
+	e.history = append(e.history, event)
+	if len(e.history) > maxEventHistory {
+		e.history = e.history[len(e.history)-maxEventHistory:]
+	}
+
+	for ch := range e.subscribers {
+		select {
+		case ch <- event:
+		default:
+			// 订阅者处理过慢时丢弃该订阅，客户端可以带 Last-Event-ID 重新连接
+			delete(e.subscribers, ch)
+			close(ch)
+		}
+	}
+
+	if IsTerminal(event.Type) {
+		e.finished = true
+		e.closeLocked()
+	}
+}
+
+// Subscribe 订阅事件，返回 ID 大于 afterID 的历史事件和后续事件的通道
+// 事件中心关闭后通道被关闭，调用方结束订阅时需要调用 cancel
+func (e *Events) Subscribe(afterID int64) ([]Event, <-chan Event, func()) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	var history []Event
+	for _, event := range e.history {
+		if event.ID > afterID {
+			history = append(history, event)
+		}
+	}
+
+	ch := make(chan Event, maxEventHistory)
+	if e.closed {
+		close(ch)
+		return history, ch, func() {}
+	}
+	e.subscribers[ch] = struct{}{}
+
+	cancel := func() {
+		e.mutex.Lock()
+		defer e.mutex.Unlock()
+		if _, ok := e.subscribers[ch]; ok {
+			delete(e.subscribers, ch)
+			close(ch)
+		}
+	}
+	return history, ch, cancel
+}
+
+// Seen 判断是否发布过指定类型的事件
+func (e *Events) Seen(eventType string) bool {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	for _, event := range e.history {
+		if event.Type == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Last 返回最近发布的事件，还没有事件时返回 false
+func (e *Events) Last() (Event, bool) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if len(e.history) == 0 {
+		return Event{}, false
+	}
+	return e.history[len(e.history)-1], true
+}
+
+// Finished 判断是否已发布登录成功、失败或过期等结束事件
+func (e *Events) Finished() bool {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.finished
+}
+
+// ClaimWatcher 登记登录状态监听，只有第一次调用返回 true，调用方负责启动监听
+func (e *Events) ClaimWatcher() bool {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if e.watching || e.closed {
+		return false
+	}
+	e.watching = true
+	return true
+}
+
+// Close 关闭事件中心和所有订阅者的通道
+func (e *Events) Close() {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.closeLocked()
+}
+
+// closeLocked 关闭事件中心，调用方需持有锁
+func (e *Events) closeLocked() {
+	if e.closed {
+		return
+	}
+	e.closed = true
+	for ch := range e.subscribers {
+		delete(e.subscribers, ch)
+		close(ch)
+	}
+}
@@ -1,6 +1,8 @@
 package modules
 
 import (
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/go-rod/rod"
@@ -10,17 +12,62 @@ import (
 type Session struct {
 	ID        string
 	Browser   *rod.Browser
-	Page      *rod.Page
+	Page      *rod.Page // 会话创建时打开的页面，之后不再替换，模块通过导航切换页面
 	CreatedAt time.Time
 	Module    Module
 	Data      map[string]interface{} // 存储模块特定数据
+	Events    *Events                // 登录事件，供事件流和回调使用
+	QR        *QRTracker             // 当前登录二维码的版本和有效期
+
+	// mutex 串行化同一会话的页面操作，复制会话时共享
+	mutex *sync.Mutex
+}
+
+// NewSession 创建会话
+func NewSession(id string, browser *rod.Browser, page *rod.Page, module Module) *Session {
+	return &Session{
+		ID:        id,
+		Browser:   browser,
+		Page:      page,
+		CreatedAt: time.Now(),
+		Module:    module,
+		Data:      make(map[string]interface{}),
+		Events:    NewEvents(),
+		QR:        NewQRTracker(),
+		mutex:     &sync.Mutex{},
+	}
+}
+
+// LockPage 锁定会话的页面和 Data，模块操作页面前调用
+// 锁属于单个会话，一个会话等待或刷新二维码时不影响同一模块的其他会话
+func (s *Session) LockPage() {
+	s.mutex.Lock()
 }
 
-// Module 接口定义
+// UnlockPage 解锁会话的页面和 Data
+func (s *Session) UnlockPage() {
+	s.mutex.Unlock()
+}
+
+// Module 所有模块都要实现的核心接口
+// 支持的登录方式通过 QRLogin、SMSLogin、PasswordLogin 等可选接口声明
 type Module interface {
 	// Name 返回模块名称
 	Name() string
 
+	// Metadata 返回模块的描述信息
+	Metadata() Metadata
+
+	// CheckLogin 检查是否登录成功
+	// 返回是否登录成功和错误信息
+	CheckLogin(session *Session) (bool, map[string]string, error)
+
+	// Close 关闭会话资源
+	Close(session *Session) error
+}
+
+// QRLogin 支持扫码登录的模块
+type QRLogin interface {
 	// GetLoginQRCode 获取登录二维码URL
 	// 返回二维码图片的URL
 	GetLoginQRCode(session *Session) (string, error)
@@ -28,7 +75,10 @@ type Module interface {
 	// GetLoginQRCodeImage 获取登录二维码图片内容
 	// 返回二维码图片的字节数据
 	GetLoginQRCodeImage(session *Session) ([]byte, error)
+}
 
+// SMSLogin 支持短信验证码登录的模块
+type SMSLogin interface {
 	// PrepareSMSLogin 准备短信登录页面
 	// 返回登录页面相关信息
 	PrepareSMSLogin(session *Session) (info map[string]interface{}, err error)
@@ -40,13 +90,98 @@ type Module interface {
 	// VerifySMSCode 验证短信验证码并提交登录
 	// smsCode: 短信验证码
 	VerifySMSCode(session *Session, smsCode string) error
+}
 
-	// CheckLogin 检查是否登录成功
-	// 返回是否登录成功和错误信息
-	CheckLogin(session *Session) (bool, map[string]string, error)
+// PasswordCredentials 账号密码登录的参数
+type PasswordCredentials struct {
+	Username string
+	Password string
+	// Remember 勾选站点的"记住我"或"下次自动登录"
+	Remember bool
+}
 
-	// Close 关闭会话资源
-	Close(session *Session) error
+// 账号密码提交后的状态
+const (
+	// PasswordSubmitted 已提交，登录结果通过 CheckLogin 获取
+	PasswordSubmitted = "submitted"
+	// PasswordCaptchaRequired 站点要求完成验证码，需要稍后重新提交或改用其他登录方式
+	PasswordCaptchaRequired = "captcha_required"
+	// PasswordSecondFactorRequired 站点要求输入短信等二次验证码，通过 SubmitSecondFactor 提交
+	PasswordSecondFactorRequired = "second_factor_required"
+)
+
+// PasswordResult 账号密码提交后的状态
+type PasswordResult struct {
+	State string
+	// Message 站点的提示，例如验证码已发送到的手机号
+	Message string
+}
+
+// PasswordLogin 支持账号密码登录的模块
+type PasswordLogin interface {
+	// LoginWithPassword 输入账号密码并提交登录
+	// 账号或密码错误时返回 login_rejected，其他中间状态通过 PasswordResult 返回
+	LoginWithPassword(session *Session, credentials PasswordCredentials) (PasswordResult, error)
+
+	// SubmitSecondFactor 提交二次验证码，登录结果通过 CheckLogin 获取
+	SubmitSecondFactor(session *Session, code string) error
+}
+
+// 登录方式
+const (
+	// CapabilityQR 扫码登录
+	CapabilityQR = "qr"
+	// CapabilitySMS 短信验证码登录
+	CapabilitySMS = "sms"
+	// CapabilityPassword 账号密码登录
+	CapabilityPassword = "password"
+)
+
+// CapabilityFilter 实现了多种登录接口、但只启用其中一部分的模块，例如按定义文件启用登录方式的声明式模块
+type CapabilityFilter interface {
+	// Supports 返回是否启用指定的登录方式
+	Supports(capability string) bool
+}
+
+// supports 判断模块是否启用了登录方式，未实现 CapabilityFilter 的模块按实现的接口判断
+func supports(module Module, capability string) bool {
+	if filter, ok := module.(CapabilityFilter); ok {
+		return filter.Supports(capability)
+	}
+	return true
+}
+
+// AsQRLogin 返回模块的扫码登录接口
+func AsQRLogin(module Module) (QRLogin, bool) {
+	qr, ok := module.(QRLogin)
+	return qr, ok && supports(module, CapabilityQR)
+}
+
+// AsSMSLogin 返回模块的短信登录接口
+func AsSMSLogin(module Module) (SMSLogin, bool) {
+	sms, ok := module.(SMSLogin)
+	return sms, ok && supports(module, CapabilitySMS)
+}
+
+// AsPasswordLogin 返回模块的账号密码登录接口
+func AsPasswordLogin(module Module) (PasswordLogin, bool) {
+	password, ok := module.(PasswordLogin)
+	return password, ok && supports(module, CapabilityPassword)
+}
+
+// Capabilities 返回模块支持的登录方式
+func Capabilities(module Module) []string {
+	capabilities := []string{}
+	if _, ok := AsQRLogin(module); ok {
+		capabilities = append(capabilities, CapabilityQR)
+	}
+	if _, ok := AsSMSLogin(module); ok {
+		capabilities = append(capabilities, CapabilitySMS)
+	}
+	if _, ok := AsPasswordLogin(module); ok {
+		capabilities = append(capabilities, CapabilityPassword)
+	}
+	return capabilities
 }
 
 // ModuleRegistry 模块注册表
@@ -61,7 +196,7 @@ func NewModuleRegistry() *ModuleRegistry {
 	}
 }
 
-// Register 注册模块
+// Register 注册模块，同名模块会被覆盖
 func (r *ModuleRegistry) Register(module Module) {
 	r.modules[module.Name()] = module
 }
@@ -72,11 +207,12 @@ func (r *ModuleRegistry) Get(name string) (Module, bool) {
 	return module, exists
 }
 
-// List 获取所有模块名称
+// List 按名称顺序获取所有模块名称
 func (r *ModuleRegistry) List() []string {
 	names := make([]string, 0, len(r.modules))
 	for name := range r.modules {
 		names = append(names, name)
 	}
+	sort.Strings(names)
 	return names
 }
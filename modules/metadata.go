@@ -0,0 +1,31 @@
+package modules
+
+// Metadata 模块的描述信息，供前端选择登录方式和渲染登录界面
+type Metadata struct {
+	// DisplayName 展示名称
+	DisplayName string `json:"display_name"`
+	// Site 目标站点地址
+	Site string `json:"site"`
+	// Version 模块版本，站点改版后模块更新时递增
+	Version string `json:"version"`
+	// Inputs 各登录方式需要用户提供的输入
+	Inputs []Input `json:"inputs"`
+	// QRLifetime 二维码的大致有效期（秒），不支持扫码登录时为 0
+	QRLifetime int `json:"qr_lifetime_seconds,omitempty"`
+	// CookieDomains 登录后得到的 cookies 所属的域名
+	CookieDomains []string `json:"cookie_domains"`
+}
+
+// Input 登录需要用户提供的一项输入
+type Input struct {
+	// Name 请求参数名，例如 phone_number、sms_code、username、password
+	Name string `json:"name"`
+	// Method 所属的登录方式，取值与 Capabilities 相同
+	Method string `json:"method"`
+	// Label 展示名称
+	Label string `json:"label"`
+	// Pattern 输入格式的正则表达式，为空时不限制
+	Pattern string `json:"pattern,omitempty"`
+	// Secret 是否为密码等敏感输入
+	Secret bool `json:"secret,omitempty"`
+}
@@ -0,0 +1,21 @@
+package modules
+
+import (
+	"github.com/go-rod/rod"
+)
+
+// 要求二次验证时页面上的常见提示
+// 不包含"短信验证"等登录页面本身可能出现的文字，例如"短信验证码登录"
+var secondFactorTexts = []string{
+	"身份验证",
+	"二次验证",
+	"两步验证",
+	"验证码已发送",
+	"请输入短信验证码",
+}
+
+// DetectSecondFactor 检查提交账号密码后页面是否要求二次验证
+// selectors 为站点二次验证弹窗或输入框的选择器，可见文本中有二次验证提示时同样返回 true
+func DetectSecondFactor(page *rod.Page, selectors []string) (bool, error) {
+	return detectSignals(page, pageSignals{Selectors: selectors, Texts: secondFactorTexts})
+}
@@ -0,0 +1,134 @@
+package modules
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"sync"
+	"time"
+
+	"github.com/go-rod/rod"
+	"github.com/makiuchi-d/gozxing"
+	"github.com/makiuchi-d/gozxing/qrcode"
+)
+
+// QRCode 会话当前的登录二维码
+type QRCode struct {
+	// Version 二维码版本，每次生成或刷新后加 1
+	Version int `json:"version"`
+	// URL 二维码图片地址，canvas 绘制的二维码为空
+	URL   string `json:"url,omitempty"`
+	Image []byte `json:"-"`
+	// Payload 二维码解码后的内容，通常是登录链接，解码失败时为空
+	Payload   string    `json:"payload,omitempty"`
+	FetchedAt time.Time `json:"fetched_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Expired 判断二维码是否已超过有效期
+func (q QRCode) Expired() bool {
+	return !q.ExpiresAt.IsZero() && time.Now().After(q.ExpiresAt)
+}
+
+// QRTracker 记录会话当前二维码的版本和有效期
+type QRTracker struct {
+	mutex   sync.Mutex
+	current QRCode
+}
+
+// NewQRTracker 创建二维码记录
+func NewQRTracker() *QRTracker {
+	return &QRTracker{}
+}
+
+// Current 返回当前二维码，还没有生成过二维码时返回 false
+func (t *QRTracker) Current() (QRCode, bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.current, t.current.Version > 0
+}
+
+// update 记录新的二维码并递增版本
+func (t *QRTracker) update(img []byte, url string, ttl time.Duration) QRCode {
+	// 解码失败不影响二维码图片的使用
+	payload, _ := DecodeQRCode(img)
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	now := time.Now()
+	t.current = QRCode{
+		Version:   t.current.Version + 1,
+		URL:       url,
+		Image:     img,
+		Payload:   payload,
+		FetchedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}
+	return t.current
+}
+
+// UpdateQRCode 记录会话的新二维码，并发布 qr_ready 或 qr_refreshed 事件
+// ttl 为站点二维码的大致有效期
+func UpdateQRCode(session *Session, img []byte, url string, ttl time.Duration) QRCode {
+	qr := session.QR.update(img, url, ttl)
+
+	eventType := EventQRRefreshed
+	if qr.Version == 1 {
+		eventType = EventQRReady
+	}
+	data := map[string]interface{}{
+		"version":    qr.Version,
+		"expires_at": qr.ExpiresAt,
+	}
+	if qr.Payload != "" {
+		data["payload"] = qr.Payload
+	}
+	session.Events.Publish(Event{Type: eventType, Data: data})
+	return qr
+}
+
+// DecodeQRCode 解码二维码图片，返回其中的文本内容，支持 PNG、JPEG 和 GIF
+func DecodeQRCode(data []byte) (string, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("无法读取二维码图片: %w", err)
+	}
+
+	bitmap, err := gozxing.NewBinaryBitmapFromImage(img)
+	if err != nil {
+		return "", fmt.Errorf("无法读取二维码图片: %w", err)
+	}
+
+	hints := map[gozxing.DecodeHintType]interface{}{
+		gozxing.DecodeHintType_TRY_HARDER: true,
+	}
+	result, err := qrcode.NewQRCodeReader().Decode(bitmap, hints)
+	if err != nil {
+		return "", fmt.Errorf("无法识别二维码: %w", err)
+	}
+	return result.GetText(), nil
+}
+
+// QRRefresher 支持自动刷新二维码的模块
+// 登录监听定期调用 RefreshQRCode，二维码过期时点击页面上的刷新按钮或重新加载，返回是否生成了新的二维码
+type QRRefresher interface {
+	RefreshQRCode(session *Session) (bool, error)
+}
+
+// 二维码过期时页面上的常见提示
+var qrExpiredTexts = []string{
+	"二维码已失效",
+	"二维码已过期",
+	"二维码失效",
+	"二维码过期",
+}
+
+// DetectQRExpired 检查页面上的二维码是否已过期
+// selectors 为站点在二维码过期时显示的遮罩或刷新按钮，可见文本中有过期提示时同样返回 true
+func DetectQRExpired(page *rod.Page, selectors []string) (bool, error) {
+	return detectSignals(page, pageSignals{Selectors: selectors, Texts: qrExpiredTexts})
+}
@@ -0,0 +1,30 @@
+package modules
+
+import (
+	"github.com/go-rod/rod"
+)
+
+// 扫码后等待手机确认时页面上的常见提示
+var qrScannedTexts = []string{
+	"扫描成功",
+	"扫码成功",
+	"已扫码",
+	"请在手机上确认",
+	"请在手机端确认",
+	"在手机上确认登录",
+}
+
+// 检查页面可见文本中是否有扫码成功的提示
+const qrScannedJS = `(texts) => {
+	const text = document.body ? document.body.innerText : '';
+	return texts.some(t => text.includes(t));
+}`
+
+// DetectQRScanned 检查页面是否显示已扫码、等待确认的提示
+func DetectQRScanned(page *rod.Page) (bool, error) {
+	res, err := page.Eval(qrScannedJS, qrScannedTexts)
+	if err != nil {
+		return false, err
+	}
+	return res.Value.Bool(), nil
+}
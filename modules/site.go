@@ -0,0 +1,17 @@
+package modules
+
+import (
+	"net/url"
+	"strings"
+)
+
+// OnSite 判断页面地址是否属于 host 或其子域名
+// about:blank 等空白页面、浏览器内部页面和其他站点都返回 false
+func OnSite(pageURL, host string) bool {
+	u, err := url.Parse(pageURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return false
+	}
+	hostname := strings.ToLower(u.Hostname())
+	return hostname == host || strings.HasSuffix(hostname, "."+host)
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"log"
+	"net/http"
+
+	"textsurf/apperr"
+	"textsurf/modules"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 账号密码提交后的状态对应的登录事件
+var passwordEvents = map[string]string{
+	modules.PasswordSubmitted:            modules.EventPasswordSubmitted,
+	modules.PasswordCaptchaRequired:      modules.EventCaptchaRequired,
+	modules.PasswordSecondFactorRequired: modules.EventSecondFactorRequired,
+}
+
+// handlePasswordLogin 输入账号密码并提交登录
+// 返回的 status 为 submitted、captcha_required 或 second_factor_required，同时发布对应的登录事件
+func handlePasswordLogin(c *gin.Context) {
+	sessionID := c.Param("session_id")
+	moduleName := c.Param("module")
+
+	var req struct {
+		Username string `json:"username" binding:"required"`
+		Password string `json:"password" binding:"required"`
+		Remember bool   `json:"remember"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, apperr.Wrap(err, apperr.InvalidRequest, "Invalid request body"))
+		return
+	}
+
+	// 不记录账号和密码
+	log.Printf("收到账号密码登录请求: module=%s, session_id=%s\n", moduleName, sessionID)
+
+	session, ok := moduleSession(c, moduleName, sessionID)
+	if !ok {
+		return
+	}
+
+	passwordModule, err := passwordLogin(session.Module)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	if err := validateInput(session.Module, "username", req.Username); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	var result modules.PasswordResult
+	err = runModule(c, session, func(session *modules.Session) error {
+		var err error
+		result, err = passwordModule.LoginWithPassword(session, modules.PasswordCredentials{
+			Username: req.Username,
+			Password: req.Password,
+			Remember: req.Remember,
+		})
+		return err
+	})
+	if err != nil {
+		log.Printf("账号密码登录失败: %v\n", err)
+		respondError(c, apperr.Wrap(err, apperr.Internal, "Failed to log in with password"))
+		return
+	}
+
+	if result.State == "" {
+		result.State = modules.PasswordSubmitted
+	}
+	event := modules.Event{Type: passwordEvents[result.State]}
+	if result.Message != "" {
+		event.Data = map[string]interface{}{"message": result.Message}
+	}
+	session.Events.Publish(event)
+
+	// 需要完成验证码时不启动监听，避免监听把验证码当作登录失败
+	if result.State != modules.PasswordCaptchaRequired {
+		startLoginWatch(session)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"session_id": sessionID,
+		"module":     moduleName,
+		"status":     result.State,
+		"message":    result.Message,
+	})
+}
+
+// handleSubmitSecondFactor 提交二次验证码
+func handleSubmitSecondFactor(c *gin.Context) {
+	sessionID := c.Param("session_id")
+	moduleName := c.Param("module")
+
+	var req struct {
+		Code string `json:"code" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, apperr.Wrap(err, apperr.InvalidRequest, "Invalid request body"))
+		return
+	}
+
+	log.Printf("收到二次验证请求: module=%s, session_id=%s\n", moduleName, sessionID)
+
+	session, ok := moduleSession(c, moduleName, sessionID)
+	if !ok {
+		return
+	}
+
+	passwordModule, err := passwordLogin(session.Module)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	if err := validateInput(session.Module, "code", req.Code); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	err = runModule(c, session, func(session *modules.Session) error {
+		return passwordModule.SubmitSecondFactor(session, req.Code)
+	})
+	if err != nil {
+		log.Printf("提交二次验证码失败: %v\n", err)
+		respondError(c, apperr.Wrap(err, apperr.Internal, "Failed to submit second factor code"))
+		return
+	}
+	startLoginWatch(session)
+
+	c.JSON(http.StatusOK, gin.H{
+		"session_id": sessionID,
+		"module":     moduleName,
+		"status":     "verified",
+		"message":    "二次验证码已提交，等待登录结果",
+	})
+}
+
+// moduleSession 查找属于 moduleName 的会话，不存在或模块不匹配时写入错误响应并返回 false
+func moduleSession(c *gin.Context, moduleName, sessionID string) (*modules.Session, bool) {
+	session, exists := sessionManager.GetSession(sessionID)
+	if !exists {
+		respondError(c, apperr.New(apperr.NotFound, "Session '%s' not found", sessionID))
+		return nil, false
+	}
+
+	if session.Module.Name() != moduleName {
+		respondError(c, apperr.New(apperr.InvalidRequest, "Session does not belong to module '%s'", moduleName))
+		return nil, false
+	}
+	return session, true
+}
@@ -0,0 +1,234 @@
+package pool
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/stealth"
+)
+
+// 空闲超过该时间的页面会被关闭，直到只剩 min 个
+const idleTimeout = time.Minute
+
+// 重置页面的超时时间
+const resetTimeout = 5 * time.Second
+
+// Tab 池中的页面，每个页面使用独立的无痕上下文，cookies 互不影响
+type Tab struct {
+	Page *rod.Page
+	// Context 页面所属的无痕上下文，用于设置和清除 cookies
+	Context *rod.Browser
+
+	idleSince time.Time
+}
+
+// close 关闭页面和所属的无痕上下文
+func (t *Tab) close() {
+	t.Page.Close()
+	t.Context.Close()
+}
+
+// Stats 页面池状态
+type Stats struct {
+	Size int `json:"size"`
+	Idle int `json:"idle"`
+	Min  int `json:"min"`
+	Max  int `json:"max"`
+}
+
+// Pool 预热的 stealth 页面池
+// 同时存在的页面数不超过 max，空闲页面在归还时重置
+type Pool struct {
+	browser *rod.Browser
+	min     int
+	max     int
+
+	// slots 每个存活的页面占用一个位置，限制标签页总数
+	slots chan struct{}
+	// idle 空闲页面
+	idle chan *Tab
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// New 创建页面池并预热 min 个页面
+func New(browser *rod.Browser, min, max int) (*Pool, error) {
+	if max < 1 {
+		return nil, fmt.Errorf("页面池最大数量必须大于 0")
+	}
+	if min < 0 || min > max {
+		return nil, fmt.Errorf("页面池最小数量必须在 0 到 %d 之间", max)
+	}
+
+	p := &Pool{
+		browser: browser,
+		min:     min,
+		max:     max,
+		slots:   make(chan struct{}, max),
+		idle:    make(chan *Tab, max),
+		done:    make(chan struct{}),
+	}
+
+	for i := 0; i < min; i++ {
+		tab, err := p.create()
+		if err != nil {
+			p.Close()
+			return nil, fmt.Errorf("预热页面失败: %w", err)
+		}
+		p.slots <- struct{}{}
+		tab.idleSince = time.Now()
+		p.idle <- tab
+	}
+
+	go p.reapIdle()
+
+	return p, nil
+}
+
+// Get 获取一个页面，没有空闲页面且已达到上限时等待归还或 ctx 结束
+func (p *Pool) Get(ctx context.Context) (*Tab, error) {
+	// 优先使用空闲页面
+	select {
+	case tab := <-p.idle:
+		return tab, nil
+	default:
+	}
+
+	select {
+	case tab := <-p.idle:
+		return tab, nil
+	case p.slots <- struct{}{}:
+		tab, err := p.create()
+		if err != nil {
+			<-p.slots
+			return nil, err
+		}
+		return tab, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("等待空闲页面超时: %w", ctx.Err())
+	case <-p.done:
+		return nil, fmt.Errorf("页面池已关闭")
+	}
+}
+
+// Put 重置并归还页面，重置失败的页面直接关闭
+func (p *Pool) Put(tab *Tab) {
+	select {
+	case <-p.done:
+		p.discard(tab)
+		return
+	default:
+	}
+
+	if err := reset(tab); err != nil {
+		log.Printf("重置页面失败，关闭页面: %v", err)
+		p.discard(tab)
+		return
+	}
+
+	tab.idleSince = time.Now()
+	p.idle <- tab
+}
+
+// Stats 返回页面池状态
+func (p *Pool) Stats() Stats {
+	return Stats{
+		Size: len(p.slots),
+		Idle: len(p.idle),
+		Min:  p.min,
+		Max:  p.max,
+	}
+}
+
+// Close 关闭页面池和所有空闲页面，使用中的页面在归还时关闭
+func (p *Pool) Close() {
+	p.closeOnce.Do(func() {
+		close(p.done)
+		for {
+			select {
+			case tab := <-p.idle:
+				p.discard(tab)
+			default:
+				return
+			}
+		}
+	})
+}
+
+// create 在新的无痕上下文中创建 stealth 页面
+func (p *Pool) create() (*Tab, error) {
+	incognito, err := p.browser.Incognito()
+	if err != nil {
+		return nil, fmt.Errorf("创建无痕上下文失败: %w", err)
+	}
+
+	page, err := stealth.Page(incognito)
+	if err != nil {
+		incognito.Close()
+		return nil, fmt.Errorf("创建 stealth 页面失败: %w", err)
+	}
+
+	return &Tab{Page: page, Context: incognito}, nil
+}
+
+// discard 关闭页面并释放位置
+func (p *Pool) discard(tab *Tab) {
+	tab.close()
+	<-p.slots
+}
+
+// reapIdle 定期关闭长时间空闲的页面，保留 min 个
+func (p *Pool) reapIdle() {
+	ticker := time.NewTicker(idleTimeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-ticker.C:
+		}
+
+		for n := len(p.idle); n > 0; n-- {
+			var tab *Tab
+			select {
+			case tab = <-p.idle:
+			default:
+			}
+			if tab == nil {
+				break
+			}
+
+			if len(p.slots) > p.min && time.Since(tab.idleSince) > idleTimeout {
+				p.discard(tab)
+				continue
+			}
+			p.idle <- tab
+		}
+	}
+}
+
+// reset 清除上一次使用留下的存储、cookies 和请求头，并回到空白页
+func reset(tab *Tab) error {
+	page := tab.Page.Timeout(resetTimeout)
+	defer page.CancelTimeout()
+
+	// 清除当前站点的 localStorage 和 sessionStorage，失败时忽略
+	_, _ = page.Eval(`() => { try { localStorage.clear(); sessionStorage.clear(); } catch (e) {} }`)
+
+	if err := page.Navigate("about:blank"); err != nil {
+		return err
+	}
+	if err := tab.Context.SetCookies(nil); err != nil {
+		return err
+	}
+	if _, err := page.SetExtraHeaders(nil); err != nil {
+		return err
+	}
+	return nil
+}
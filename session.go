@@ -0,0 +1,54 @@
+package main
+
+import (
+	"log"
+	"net/http"
+
+	"textsurf/apperr"
+
+	"github.com/gin-gonic/gin"
+)
+
+// handleListSessions 列出所有登录会话的模块、存活时间、状态、当前页面地址和最近活动时间
+func handleListSessions(c *gin.Context) {
+	list := sessionManager.List()
+	c.JSON(http.StatusOK, gin.H{
+		"sessions": list,
+		"count":    len(list),
+		"mode":     sessionManager.Mode(),
+	})
+}
+
+// handleInspectSession 获取单个会话的运行状态，不计入会话的活动
+func handleInspectSession(c *gin.Context) {
+	sessionID := c.Param("id")
+	info, exists := sessionManager.Inspect(sessionID)
+	if !exists {
+		respondError(c, apperr.New(apperr.NotFound, "Session '%s' not found", sessionID))
+		return
+	}
+	c.JSON(http.StatusOK, info)
+}
+
+// handleDeleteSession 取消登录并关闭会话的浏览器资源
+func handleDeleteSession(c *gin.Context) {
+	sessionID := c.Param("session_id")
+	moduleName := c.Param("module")
+
+	if _, ok := moduleSession(c, moduleName, sessionID); !ok {
+		return
+	}
+
+	// 并发删除时只有一个请求成功
+	if !sessionManager.DeleteSession(sessionID) {
+		respondError(c, apperr.New(apperr.NotFound, "Session '%s' not found", sessionID))
+		return
+	}
+	log.Printf("会话已删除: module=%s, session_id=%s\n", moduleName, sessionID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"session_id": sessionID,
+		"module":     moduleName,
+		"status":     "deleted",
+	})
+}
@@ -0,0 +1,79 @@
+package sessions
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-rod/rod/lib/proto"
+)
+
+// Credential 登录成功后保存的命名凭证
+type Credential struct {
+	Name      string                 `json:"name"`
+	Module    string                 `json:"module"`
+	Cookies   []*proto.NetworkCookie `json:"-"`
+	CreatedAt time.Time              `json:"created_at"`
+}
+
+// CredentialStore 命名凭证存储，仅保存在内存中
+type CredentialStore struct {
+	credentials map[string]*Credential
+	mutex       sync.RWMutex
+}
+
+// NewCredentialStore 创建新的凭证存储
+func NewCredentialStore() *CredentialStore {
+	return &CredentialStore{
+		credentials: make(map[string]*Credential),
+	}
+}
+
+// Save 保存凭证，同名凭证会被覆盖
+func (s *CredentialStore) Save(name, module string, cookies []*proto.NetworkCookie) *Credential {
+	credential := &Credential{
+		Name:      name,
+		Module:    module,
+		Cookies:   cookies,
+		CreatedAt: time.Now(),
+	}
+
+	s.mutex.Lock()
+	s.credentials[name] = credential
+	s.mutex.Unlock()
+
+	return credential
+}
+
+// Get 获取凭证
+func (s *CredentialStore) Get(name string) (*Credential, bool) {
+	s.mutex.RLock()
+	credential, exists := s.credentials[name]
+	s.mutex.RUnlock()
+	return credential, exists
+}
+
+// Delete 删除凭证，返回凭证是否存在
+func (s *CredentialStore) Delete(name string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	_, exists := s.credentials[name]
+	delete(s.credentials, name)
+	return exists
+}
+
+// List 获取所有凭证，按名称排序
+func (s *CredentialStore) List() []*Credential {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	list := make([]*Credential, 0, len(s.credentials))
+	for _, credential := range s.credentials {
+		list = append(list, credential)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
@@ -0,0 +1,101 @@
+package sessions
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"textsurf/modules"
+)
+
+// 读取会话当前页面地址的超时时间，浏览器无响应时地址为空
+const pageInfoTimeout = 2 * time.Second
+
+// StateCreated 会话已创建、尚未开始登录
+const StateCreated = "created"
+
+// Info 会话的运行状态
+type Info struct {
+	ID        string    `json:"id"`
+	Module    string    `json:"module"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	// AgeSeconds 会话已存在的秒数
+	AgeSeconds float64 `json:"age_seconds"`
+	// State 最近一次登录事件的类型，例如 qr_ready、sms_sent、logged_in，还没有事件时为 created
+	State string `json:"state"`
+	// URL 会话页面的当前地址，浏览器无响应时为空
+	URL string `json:"url,omitempty"`
+	// LastActivity 客户端最近一次访问会话或会话最近一次发布事件的时间
+	LastActivity time.Time `json:"last_activity"`
+}
+
+// Inspect 获取单个会话的运行状态，查看状态不记录为会话的活动
+func (m *Manager) Inspect(sessionID string) (Info, bool) {
+	m.mutex.RLock()
+	session, exists := m.sessions[sessionID]
+	activity := m.activity[sessionID]
+	m.mutex.RUnlock()
+
+	if !exists {
+		return Info{}, false
+	}
+	return inspect(session, activity), true
+}
+
+// List 按创建时间顺序获取所有会话的运行状态，并发读取各会话的页面地址
+func (m *Manager) List() []Info {
+	m.mutex.RLock()
+	sessions := make([]*modules.Session, 0, len(m.sessions))
+	activity := make([]time.Time, 0, len(m.sessions))
+	for id, session := range m.sessions {
+		sessions = append(sessions, session)
+		activity = append(activity, m.activity[id])
+	}
+	m.mutex.RUnlock()
+
+	infos := make([]Info, len(sessions))
+	var wg sync.WaitGroup
+	for i := range sessions {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			infos[i] = inspect(sessions[i], activity[i])
+		}(i)
+	}
+	wg.Wait()
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].CreatedAt.Before(infos[j].CreatedAt)
+	})
+	return infos
+}
+
+// inspect 汇总会话的运行状态
+func inspect(session *modules.Session, lastActivity time.Time) Info {
+	info := Info{
+		ID:           session.ID,
+		Module:       session.Module.Name(),
+		CreatedAt:    session.CreatedAt,
+		ExpiresAt:    session.CreatedAt.Add(TTL),
+		AgeSeconds:   time.Since(session.CreatedAt).Seconds(),
+		State:        StateCreated,
+		LastActivity: lastActivity,
+	}
+
+	if event, ok := session.Events.Last(); ok {
+		info.State = event.Type
+		if event.Timestamp.After(info.LastActivity) {
+			info.LastActivity = event.Timestamp
+		}
+	}
+
+	if session.Page != nil {
+		page := session.Page.Timeout(pageInfoTimeout)
+		if pageInfo, err := page.Info(); err == nil {
+			info.URL = pageInfo.URL
+		}
+		page.CancelTimeout()
+	}
+	return info
+}
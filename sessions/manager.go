@@ -1,9 +1,12 @@
 package sessions
 
 import (
+	"context"
+	"log"
 	"sync"
 	"time"
 
+	"textsurf/apperr"
 	"textsurf/modules"
 
 	"github.com/go-rod/rod"
@@ -12,16 +15,37 @@ import (
 	"github.com/google/uuid"
 )
 
+// 会话浏览器模式
+const (
+	// ModeIncognito 会话使用共享浏览器的独立无痕上下文，cookies 和存储互相隔离
+	ModeIncognito = "incognito"
+	// ModeProcess 每个会话启动独立的浏览器进程，隔离性最好但占用内存较多
+	ModeProcess = "process"
+)
+
+// TTL 会话的最长存活时间，超过后会话被清理
+const TTL = 20 * time.Minute
+
+// 关闭会话浏览器的超时时间，浏览器无响应时放弃等待，避免卡住删除和清理
+const closeTimeout = 10 * time.Second
+
 // Manager 会话管理器
 type Manager struct {
 	sessions map[string]*modules.Session
+	// activity 会话最近一次被客户端访问的时间
+	activity map[string]time.Time
 	mutex    sync.RWMutex
+	// shared 无痕模式下所有会话共享的浏览器，为空时使用独立进程模式
+	shared *rod.Browser
 }
 
 // NewManager 创建新的会话管理器
-func NewManager() *Manager {
+// shared 不为空时会话运行在共享浏览器的无痕上下文中，否则每个会话启动独立的浏览器进程
+func NewManager(shared *rod.Browser) *Manager {
 	manager := &Manager{
 		sessions: make(map[string]*modules.Session),
+		activity: make(map[string]time.Time),
+		shared:   shared,
 	}
 
 	// 启动清理过期会话的 goroutine
@@ -30,60 +54,128 @@ func NewManager() *Manager {
 	return manager
 }
 
+// Mode 返回会话浏览器模式
+func (m *Manager) Mode() string {
+	if m.shared != nil {
+		return ModeIncognito
+	}
+	return ModeProcess
+}
+
 // CreateSession 创建新会话
+// 无痕模式下 headless 由共享浏览器决定
 func (m *Manager) CreateSession(module modules.Module, headless bool) (*modules.Session, error) {
-	// 启动浏览器（启用反检测）
-	url := launcher.New().
-		Headless(headless).
-		Set("disable-blink-features", "AutomationControlled").
-		Set("disable-web-security", "true").
-		Set("disable-features", "IsolateOrigins,site-per-process").
-		MustLaunch()
-
-	browser := rod.New().ControlURL(url).MustConnect()
+	browser, err := m.newBrowser(headless)
+	if err != nil {
+		return nil, err
+	}
 
 	// 创建 stealth 页面
 	page, err := stealth.Page(browser)
 	if err != nil {
-		browser.MustClose()
-		return nil, err
+		browser.Close()
+		return nil, apperr.Wrap(err, apperr.BrowserCrashed, "创建页面失败")
 	}
 
 	// 创建会话
-	session := &modules.Session{
-		ID:        uuid.New().String(),
-		Browser:   browser,
-		Page:      page,
-		CreatedAt: time.Now(),
-		Module:    module,
-		Data:      make(map[string]interface{}),
-	}
+	session := modules.NewSession(uuid.New().String(), browser, page, module)
 
 	// 存储会话
 	m.mutex.Lock()
 	m.sessions[session.ID] = session
+	m.activity[session.ID] = session.CreatedAt
 	m.mutex.Unlock()
 
 	return session, nil
 }
 
-// GetSession 获取会话
+// newBrowser 为会话创建浏览器
+// 无痕模式下返回共享浏览器的新无痕上下文，关闭时只销毁该上下文
+func (m *Manager) newBrowser(headless bool) (*rod.Browser, error) {
+	if m.shared != nil {
+		incognito, err := m.shared.Incognito()
+		if err != nil {
+			return nil, apperr.Wrap(err, apperr.BrowserCrashed, "创建无痕上下文失败")
+		}
+		return incognito, nil
+	}
+
+	// 启动独立的浏览器进程（启用反检测）
+	url, err := launcher.New().
+		Headless(headless).
+		Set("disable-blink-features", "AutomationControlled").
+		Set("disable-web-security", "true").
+		Set("disable-features", "IsolateOrigins,site-per-process").
+		Launch()
+	if err != nil {
+		return nil, apperr.Wrap(err, apperr.BrowserCrashed, "启动浏览器失败")
+	}
+
+	browser := rod.New().ControlURL(url)
+	if err := browser.Connect(); err != nil {
+		return nil, apperr.Wrap(err, apperr.BrowserCrashed, "连接浏览器失败")
+	}
+	return browser, nil
+}
+
+// GetSession 获取会话，并记录为会话的最近活动
 func (m *Manager) GetSession(sessionID string) (*modules.Session, bool) {
-	m.mutex.RLock()
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
 	session, exists := m.sessions[sessionID]
-	m.mutex.RUnlock()
+	if exists {
+		m.activity[sessionID] = time.Now()
+	}
 	return session, exists
 }
 
-// DeleteSession 删除会话
-func (m *Manager) DeleteSession(sessionID string) {
+// Exists 判断会话是否仍然存在，不记录为会话的活动
+func (m *Manager) Exists(sessionID string) bool {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	_, exists := m.sessions[sessionID]
+	return exists
+}
+
+// DeleteSession 删除会话并关闭浏览器资源，会话不存在时返回 false
+func (m *Manager) DeleteSession(sessionID string) bool {
 	m.mutex.Lock()
-	defer m.mutex.Unlock()
+	session, exists := m.sessions[sessionID]
+	delete(m.sessions, sessionID)
+	delete(m.activity, sessionID)
+	m.mutex.Unlock()
 
-	if session, exists := m.sessions[sessionID]; exists {
-		// 关闭浏览器资源
-		session.Module.Close(session)
-		delete(m.sessions, sessionID)
+	if exists {
+		closeSession(session, apperr.NotFound, "Session was closed before login completed")
+	}
+	return exists
+}
+
+// closeSession 关闭浏览器资源，登录流程尚未结束时通知会话过期
+// 调用方不能持有锁，浏览器无响应时最多等待 closeTimeout
+func closeSession(session *modules.Session, code apperr.Code, reason string) {
+	// 已登录成功或失败的会话被删除时不再发送过期事件
+	if !session.Events.Finished() {
+		session.Events.Publish(modules.Event{
+			Type: modules.EventExpired,
+			Data: map[string]interface{}{"code": code, "reason": reason},
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), closeTimeout)
+	defer cancel()
+
+	bound := *session
+	if session.Browser != nil {
+		bound.Browser = session.Browser.Context(ctx)
+	}
+	if session.Page != nil {
+		bound.Page = session.Page.Context(ctx)
+	}
+	if err := session.Module.Close(&bound); err != nil {
+		log.Printf("关闭会话失败: session_id=%s, error=%v\n", session.ID, err)
 	}
 }
 
@@ -93,15 +185,23 @@ func (m *Manager) cleanupExpiredSessions() {
 	defer ticker.Stop()
 
 	for range ticker.C {
+		var expired []*modules.Session
+
 		m.mutex.Lock()
 		now := time.Now()
 		for id, session := range m.sessions {
-			if now.Sub(session.CreatedAt) > time.Minute*20 {
-				session.Module.Close(session)
+			if now.Sub(session.CreatedAt) > TTL {
+				expired = append(expired, session)
 				delete(m.sessions, id)
+				delete(m.activity, id)
 			}
 		}
 		m.mutex.Unlock()
+
+		// 在锁外关闭浏览器，避免无响应的浏览器阻塞其他请求
+		for _, session := range expired {
+			closeSession(session, apperr.Timeout, "Session expired before login completed")
+		}
 	}
 }
 
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"textsurf/apperr"
+	"textsurf/modules"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 事件流的心跳间隔，避免代理因连接空闲而断开
+const sseHeartbeatInterval = 15 * time.Second
+
+// handleLoginEvents 以 SSE 推送会话的登录事件
+// 先补发历史事件，登录结束后关闭连接；服务端登录监听在登录流程开始后才启动，订阅本身不会启动监听
+// 客户端重连时通过 Last-Event-ID 请求头跳过已收到的事件
+func handleLoginEvents(c *gin.Context) {
+	sessionID := c.Param("session_id")
+	moduleName := c.Param("module")
+
+	// 获取会话
+	session, exists := sessionManager.GetSession(sessionID)
+	if !exists {
+		respondError(c, apperr.New(apperr.NotFound, "Session '%s' not found", sessionID))
+		return
+	}
+
+	// 验证模块
+	if session.Module.Name() != moduleName {
+		respondError(c, apperr.New(apperr.InvalidRequest, "Session does not belong to module '%s'", moduleName))
+		return
+	}
+
+	var lastID int64
+	if value := c.GetHeader("Last-Event-ID"); value != "" {
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || id < 0 {
+			respondError(c, apperr.New(apperr.InvalidRequest, "Invalid Last-Event-ID header"))
+			return
+		}
+		lastID = id
+	}
+
+	history, events, cancel := session.Events.Subscribe(lastID)
+	defer cancel()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	for _, event := range history {
+		if !writeEvent(c, event) {
+			return
+		}
+	}
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(sseHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case event, open := <-events:
+			if !open {
+				return
+			}
+			if !writeEvent(c, event) {
+				return
+			}
+			c.Writer.Flush()
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(c.Writer, ": ping\n\n"); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		case <-c.Request.Context().Done():
+			log.Printf("事件流客户端已断开: module=%s, session_id=%s\n", moduleName, sessionID)
+			return
+		}
+	}
+}
+
+// writeEvent 按 SSE 格式写入一个事件，写入失败时返回 false
+func writeEvent(c *gin.Context, event modules.Event) bool {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return false
+	}
+	_, err = fmt.Fprintf(c.Writer, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+	return err == nil
+}
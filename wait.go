@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"textsurf/actions"
+	"textsurf/apperr"
+
+	"github.com/go-rod/rod"
+)
+
+// 页面加载等待策略
+const (
+	// waitStable 等待页面稳定后再额外等待一段时间（默认策略）
+	waitStable = "stable"
+	// waitLoad 等待 window.onload
+	waitLoad = "load"
+	// waitDOMContentLoaded 只等待 DOMContentLoaded
+	waitDOMContentLoaded = "domcontentloaded"
+	// waitNetworkIdle 等待网络请求空闲 idle_ms 毫秒
+	waitNetworkIdle = "idle"
+	// waitNone 不等待
+	waitNone = "none"
+)
+
+// 网络空闲判定的默认时间窗口
+const defaultIdleWindow = 500 * time.Millisecond
+
+// validateWait 校验等待参数
+func (req *FetchRequest) validateWait() error {
+	switch req.WaitUntil {
+	case "":
+		req.WaitUntil = waitStable
+	case waitStable, waitLoad, waitDOMContentLoaded, waitNetworkIdle, waitNone:
+	default:
+		return fmt.Errorf("Invalid wait_until. Use 'stable', 'load', 'domcontentloaded', 'idle' or 'none'")
+	}
+
+	if req.IdleMs < 0 {
+		return fmt.Errorf("Invalid idle_ms. Must not be negative")
+	}
+	if req.Timeout < 0 {
+		return fmt.Errorf("Invalid timeout. Must not be negative")
+	}
+	return nil
+}
+
+// prepareWait 在触发导航或点击之前调用，返回触发之后执行的等待函数
+// 网络空闲需要在触发前开始监听请求，所以拆成两步
+func prepareWait(page *rod.Page, req *FetchRequest, extraSleep time.Duration) func() error {
+	switch req.WaitUntil {
+	case waitLoad:
+		return page.WaitLoad
+	case waitDOMContentLoaded:
+		return func() error {
+			return page.Wait(rod.Eval(`() => document.readyState !== 'loading'`))
+		}
+	case waitNetworkIdle:
+		idle := defaultIdleWindow
+		if req.IdleMs > 0 {
+			idle = time.Duration(req.IdleMs) * time.Millisecond
+		}
+		wait := page.WaitRequestIdle(idle, nil, nil, nil)
+		return func() error {
+			wait()
+			return page.GetContext().Err()
+		}
+	case waitNone:
+		return func() error { return nil }
+	default:
+		return func() error {
+			if err := page.WaitStable(time.Second); err != nil {
+				return err
+			}
+			// 额外等待确保页面完全加载
+			return sleepPage(page, extraSleep)
+		}
+	}
+}
+
+// waitConditions 等待元素出现、消失或 JS 条件成立
+func waitConditions(page *rod.Page, req *FetchRequest) error {
+	if req.WaitFor != "" {
+		fmt.Printf("Waiting for element: %s\n", req.WaitFor)
+		el, err := findElement(page, req.WaitFor)
+		if err != nil {
+			return err
+		}
+		if err := el.WaitVisible(); err != nil {
+			return apperr.Wrap(err, apperr.Internal, "Error waiting for element '%s' to be visible", req.WaitFor)
+		}
+	}
+
+	if req.WaitGone != "" {
+		fmt.Printf("Waiting for element to disappear: %s\n", req.WaitGone)
+		err := page.Wait(rod.Eval(`s => !document.querySelector(s)`, req.WaitGone))
+		if err != nil {
+			return apperr.Wrap(err, apperr.Internal, "Error waiting for element '%s' to disappear", req.WaitGone)
+		}
+	}
+
+	if req.WaitJS != "" {
+		fmt.Println("Waiting for JS predicate")
+		// 支持返回 Promise 的条件，结果统一转换为布尔值
+		predicate := "async () => !!(await (" + actions.JSFunction(req.WaitJS) + ")())"
+		err := page.Wait(rod.Eval(predicate).ByPromise())
+		if err != nil {
+			return apperr.Wrap(err, apperr.Internal, "Error waiting for JS predicate")
+		}
+	}
+
+	return nil
+}
+
+// sleepPage 在页面上下文内等待，超时或取消时提前返回
+func sleepPage(page *rod.Page, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	select {
+	case <-time.After(d):
+		return nil
+	case <-page.GetContext().Done():
+		return page.GetContext().Err()
+	}
+}
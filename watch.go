@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"textsurf/apperr"
+	"textsurf/modules"
+	"textsurf/sessions"
+)
+
+// 登录回调事件
+const (
+	// eventLoginSucceeded 登录成功，回调内容包含 cookies
+	eventLoginSucceeded = "login.succeeded"
+	// eventLoginFailed 登录失败，例如出现验证码、站点拒绝登录或浏览器崩溃
+	eventLoginFailed = "login.failed"
+	// eventLoginExpired 会话在登录前过期或被删除
+	eventLoginExpired = "login.expired"
+)
+
+// 服务端轮询登录状态的间隔
+const loginWatchInterval = 2 * time.Second
+
+// 单次检查登录状态和扫码状态的超时时间
+const loginCheckTimeout = 15 * time.Second
+
+// 单次刷新二维码的超时时间，刷新可能需要重新加载页面并打开登录弹窗
+const qrRefreshTimeout = 45 * time.Second
+
+// 投递回调的总时长上限，包括重试
+const webhookDeliveryTimeout = 2 * time.Minute
+
+// LoginEvent 登录回调的请求体
+type LoginEvent struct {
+	Event     string            `json:"event"`
+	SessionID string            `json:"session_id"`
+	Module    string            `json:"module"`
+	Timestamp time.Time         `json:"timestamp"`
+	Cookies   map[string]string `json:"cookies,omitempty"`
+	// CookieString Cookie 头格式的 cookies
+	CookieString string `json:"cookie_string,omitempty"`
+	// Code 和 Reason 为失败或过期的原因
+	Code   apperr.Code `json:"code,omitempty"`
+	Reason string      `json:"reason,omitempty"`
+}
+
+// startLoginWatch 启动会话的登录状态监听，每个会话只启动一次
+// 只在登录流程开始后调用（获取二维码、准备短信登录或提交账号密码），之前会话页面还是空白页，无法判断登录状态
+func startLoginWatch(session *modules.Session) {
+	if session.Events.ClaimWatcher() {
+		go watchLogin(session)
+	}
+}
+
+// watchLogin 轮询会话的登录状态并发布事件，直到登录成功、失败或会话过期
+func watchLogin(session *modules.Session) {
+	log.Printf("开始监听登录状态: module=%s, session_id=%s\n", session.Module.Name(), session.ID)
+	defer log.Printf("登录监听结束: module=%s, session_id=%s\n", session.Module.Name(), session.ID)
+
+	deadline := time.NewTimer(time.Until(session.CreatedAt.Add(sessions.TTL)))
+	defer deadline.Stop()
+	ticker := time.NewTicker(loginWatchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-deadline.C:
+			session.Events.Publish(modules.Event{
+				Type: modules.EventExpired,
+				Data: map[string]interface{}{
+					"code":   apperr.Timeout,
+					"reason": "Session expired before login completed",
+				},
+			})
+			return
+		case <-ticker.C:
+		}
+
+		// 会话已被删除或清理，过期事件由会话管理器发布
+		if !sessionManager.Exists(session.ID) {
+			return
+		}
+
+		loggedIn, cookies, scanned, err := checkLoginOnce(session)
+		switch {
+		case err != nil && loginWatchFatal(err):
+			if !sessionManager.Exists(session.ID) {
+				return
+			}
+			session.Events.Publish(modules.Event{
+				Type: modules.EventFailed,
+				Data: map[string]interface{}{
+					"code":   apperr.CodeOf(err),
+					"reason": err.Error(),
+				},
+			})
+			return
+		case err != nil:
+			// 页面尚未打开登录二维码或检查超时，继续等待
+		case loggedIn:
+			publishLoggedIn(session, cookies)
+			return
+		case scanned && !session.Events.Seen(modules.EventQRScanned):
+			session.Events.Publish(modules.Event{Type: modules.EventQRScanned})
+		}
+	}
+}
+
+// publishLoggedIn 发布登录成功事件，事件流和回调据此结束，重复发布时忽略
+func publishLoggedIn(session *modules.Session, cookies map[string]string) {
+	session.Events.Publish(modules.Event{
+		Type:    modules.EventLoggedIn,
+		Cookies: cookies,
+	})
+}
+
+// checkLoginOnce 在超时内检查一次登录状态，未登录时同时检查是否已扫码和二维码是否过期
+func checkLoginOnce(session *modules.Session) (loggedIn bool, cookies map[string]string, scanned bool, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), loginCheckTimeout)
+	defer cancel()
+
+	bound := bindSession(ctx, session)
+
+	loggedIn, cookies, err = session.Module.CheckLogin(bound)
+	if err != nil || loggedIn || bound.Page == nil {
+		return loggedIn, cookies, false, err
+	}
+
+	// 模块方法自己持有页面锁，这里只需要为扫码检查加锁，避免与 login_qr 或 /fetch 同时操作页面
+	session.LockPage()
+	scanned, _ = modules.DetectQRScanned(bound.Page)
+	session.UnlockPage()
+	if scanned {
+		return false, nil, true, nil
+	}
+
+	// 二维码过期时自动刷新，新的二维码通过 qr_refreshed 事件通知
+	if refresher, ok := session.Module.(modules.QRRefresher); ok {
+		refreshQRCode(session, refresher)
+	}
+	return false, nil, false, nil
+}
+
+// refreshQRCode 在单独的超时内刷新过期的二维码，不占用检查登录状态的超时
+func refreshQRCode(session *modules.Session, refresher modules.QRRefresher) {
+	ctx, cancel := context.WithTimeout(context.Background(), qrRefreshTimeout)
+	defer cancel()
+
+	if _, err := refresher.RefreshQRCode(bindSession(ctx, session)); err != nil {
+		log.Printf("刷新二维码失败: session_id=%s, error=%v\n", session.ID, err)
+	}
+}
+
+// loginWatchFatal 判断检查登录状态的错误是否应结束监听
+func loginWatchFatal(err error) bool {
+	switch apperr.CodeOf(err) {
+	case apperr.CaptchaDetected, apperr.LoginRejected, apperr.BrowserCrashed:
+		return true
+	}
+	return false
+}
+
+// notifyLogin 等待登录流程结束后回调 callbackURL
+func notifyLogin(session *modules.Session, callbackURL string) {
+	history, events, cancel := session.Events.Subscribe(0)
+	defer cancel()
+
+	payload := &LoginEvent{
+		Event:     eventLoginExpired,
+		SessionID: session.ID,
+		Module:    session.Module.Name(),
+		Code:      apperr.NotFound,
+		Reason:    "Session was closed before login completed",
+	}
+
+	// 先检查历史事件，再等待后续事件，通道关闭时按过期处理
+	terminal, ok := lastTerminal(history)
+	for !ok {
+		event, open := <-events
+		if !open {
+			break
+		}
+		terminal, ok = event, modules.IsTerminal(event.Type)
+	}
+
+	if ok {
+		payload.Timestamp = terminal.Timestamp
+		payload.Code, _ = terminal.Data["code"].(apperr.Code)
+		payload.Reason, _ = terminal.Data["reason"].(string)
+		switch terminal.Type {
+		case modules.EventLoggedIn:
+			payload.Event = eventLoginSucceeded
+			payload.Cookies = terminal.Cookies
+			payload.CookieString = cookieString(terminal.Cookies)
+		case modules.EventFailed:
+			payload.Event = eventLoginFailed
+		case modules.EventExpired:
+			payload.Event = eventLoginExpired
+		}
+	} else {
+		payload.Timestamp = time.Now()
+	}
+
+	ctx, cancelSend := context.WithTimeout(context.Background(), webhookDeliveryTimeout)
+	defer cancelSend()
+	if err := webhookSender.Send(ctx, callbackURL, payload.Event, payload); err != nil {
+		log.Printf("登录回调失败: session_id=%s, error=%v\n", session.ID, err)
+	}
+}
+
+// lastTerminal 返回历史事件中的结束事件
+func lastTerminal(history []modules.Event) (modules.Event, bool) {
+	for _, event := range history {
+		if modules.IsTerminal(event.Type) {
+			return event, true
+		}
+	}
+	return modules.Event{}, false
+}
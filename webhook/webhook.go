@@ -0,0 +1,141 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// 回调请求头
+const (
+	// HeaderEvent 事件类型
+	HeaderEvent = "X-TextSurf-Event"
+	// HeaderDelivery 每次投递的唯一 ID，重试时不变，接收方可用于去重
+	HeaderDelivery = "X-TextSurf-Delivery"
+	// HeaderTimestamp 签名时的 Unix 时间戳（秒）
+	HeaderTimestamp = "X-TextSurf-Timestamp"
+	// HeaderSignature 签名，格式为 sha256=<hex>，对 "<timestamp>.<body>" 计算 HMAC-SHA256
+	HeaderSignature = "X-TextSurf-Signature"
+)
+
+// 投递参数
+const (
+	// 最多尝试次数
+	maxAttempts = 5
+	// 第一次重试前的等待时间，之后每次翻倍
+	initialBackoff = time.Second
+	// 重试等待时间上限
+	maxBackoff = 30 * time.Second
+	// 单次请求超时时间
+	attemptTimeout = 10 * time.Second
+)
+
+// Sender 签名并投递回调，失败时按指数退避重试
+type Sender struct {
+	secret []byte
+	client *http.Client
+}
+
+// NewSender 创建回调发送器，secret 为签名密钥
+func NewSender(secret string) *Sender {
+	return &Sender{
+		secret: []byte(secret),
+		client: &http.Client{Timeout: attemptTimeout},
+	}
+}
+
+// ValidateURL 检查回调地址是否为 http 或 https 的绝对地址
+func ValidateURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("回调地址必须是 http 或 https 的绝对地址")
+	}
+	return nil
+}
+
+// Sign 计算签名请求头的值
+func Sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Send 投递事件，网络错误、429 和 5xx 响应会重试，直到成功、达到最多尝试次数或 ctx 结束
+func (s *Sender) Send(ctx context.Context, target, event string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("序列化回调内容失败: %w", err)
+	}
+	delivery := uuid.New().String()
+
+	backoff := initialBackoff
+	for attempt := 1; ; attempt++ {
+		retry, err := s.deliver(ctx, target, event, delivery, body)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= maxAttempts {
+			return fmt.Errorf("投递回调失败 (已尝试 %d 次): %w", attempt, err)
+		}
+
+		log.Printf("投递回调失败，%v 后重试: event=%s, delivery=%s, error=%v\n", backoff, event, delivery, err)
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("投递回调已取消: %w", ctx.Err())
+		}
+
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// deliver 发送一次回调请求，返回失败时是否可以重试
+func (s *Sender) deliver(ctx context.Context, target, event, delivery string, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+
+	// 每次尝试重新签名，接收方可以据时间戳拒绝过旧的请求
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "TextSurf-Webhook/1.0")
+	req.Header.Set(HeaderEvent, event)
+	req.Header.Set(HeaderDelivery, delivery)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, Sign(s.secret, timestamp, body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests, resp.StatusCode >= 500:
+		return true, fmt.Errorf("回调地址返回 %s", resp.Status)
+	}
+	return false, fmt.Errorf("回调地址返回 %s", resp.Status)
+}
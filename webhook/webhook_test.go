@@ -0,0 +1,123 @@
+package webhook
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestSign(t *testing.T) {
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp string
+		body      string
+		want      string
+	}{
+		{
+			name:      "payload",
+			secret:    "secret",
+			timestamp: "1700000000",
+			body:      `{"event":"login.succeeded"}`,
+			want:      "sha256=bbd04264320ab3da8ad0631e6d8486dd557ba82a4f0daab5d576c8535c0c9fb2",
+		},
+		{
+			name:      "empty secret and body",
+			timestamp: "0",
+			want:      "sha256=b849d5a581847b281957065739df36df2463d1977ea8d6e1e4e6cf33fadc68c3",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Sign([]byte(tt.secret), tt.timestamp, []byte(tt.body)); got != tt.want {
+				t.Errorf("Sign() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateURL(t *testing.T) {
+	tests := []struct {
+		url     string
+		wantErr bool
+	}{
+		{"https://example.com/hook", false},
+		{"http://127.0.0.1:8080/hook", false},
+		{"ftp://example.com/hook", true},
+		{"/relative/hook", true},
+		{"https://", true},
+		{"://bad", true},
+	}
+	for _, tt := range tests {
+		if err := ValidateURL(tt.url); (err != nil) != tt.wantErr {
+			t.Errorf("ValidateURL(%q) error = %v, wantErr %v", tt.url, err, tt.wantErr)
+		}
+	}
+}
+
+func TestSendSignsRequest(t *testing.T) {
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		body, _ := io.ReadAll(r.Body)
+		want := Sign([]byte("secret"), r.Header.Get(HeaderTimestamp), body)
+		if got := r.Header.Get(HeaderSignature); got != want {
+			t.Errorf("signature = %s, want %s", got, want)
+		}
+		if got := r.Header.Get(HeaderEvent); got != "login.succeeded" {
+			t.Errorf("event header = %s, want login.succeeded", got)
+		}
+		if r.Header.Get(HeaderDelivery) == "" {
+			t.Error("missing delivery header")
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	err := NewSender("secret").Send(context.Background(), server.URL, "login.succeeded", map[string]string{"session_id": "s1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&attempts); n != 1 {
+		t.Errorf("attempts = %d, want 1", n)
+	}
+}
+
+func TestSendRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int
+		wantErr      bool
+		wantAttempts int32
+	}{
+		{"client error is not retried", []int{http.StatusBadRequest}, true, 1},
+		{"server error is retried", []int{http.StatusServiceUnavailable, http.StatusOK}, false, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts int32
+			deliveries := make(map[string]bool)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := atomic.AddInt32(&attempts, 1)
+				deliveries[r.Header.Get(HeaderDelivery)] = true
+				w.WriteHeader(tt.statuses[int(n)-1])
+			}))
+			defer server.Close()
+
+			err := NewSender("secret").Send(context.Background(), server.URL, "login.failed", nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Send() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if n := atomic.LoadInt32(&attempts); n != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", n, tt.wantAttempts)
+			}
+			// 重试时投递 ID 不变
+			if len(deliveries) != 1 {
+				t.Errorf("delivery IDs = %d, want 1", len(deliveries))
+			}
+		})
+	}
+}